@@ -24,6 +24,7 @@ import (
 	"crypto/x509/pkix"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"flag"
@@ -34,6 +35,7 @@ import (
 	"math/big"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -535,12 +537,8 @@ func TestIntegrationSessionProperties(t *testing.T) {
 }
 
 func TestIntegrationTypeConversion(t *testing.T) {
-	err := RegisterCustomClient("uncompressed", &http.Client{Transport: &http.Transport{DisableCompression: true}})
-	if err != nil {
-		t.Fatal(err)
-	}
 	dsn := *integrationServerFlag
-	dsn += "?custom_client=uncompressed"
+	dsn += "?compressionDisabled=true"
 	db := integrationOpen(t, dsn)
 	var (
 		goTime            time.Time
@@ -556,11 +554,14 @@ func TestIntegrationTypeConversion(t *testing.T) {
 		nullFloat64Slice  NullSliceFloat64
 		nullFloat64Slice2 NullSlice2Float64
 		nullFloat64Slice3 NullSlice3Float64
+		nullTimeSlice     NullSliceTime
+		nullTimeSlice2    NullSlice2Time
+		nullTimeSlice3    NullSlice3Time
 		goMap             map[string]interface{}
 		nullMap           NullMap
 		goRow             []interface{}
 	)
-	err = db.QueryRow(`
+	err := db.QueryRow(`
 		SELECT
 			TIMESTAMP '2017-07-10 01:02:03.004 UTC',
 			CAST(NULL AS TIMESTAMP),
@@ -575,6 +576,9 @@ func TestIntegrationTypeConversion(t *testing.T) {
 			ARRAY[1.0, 2.0, NULL],
 			ARRAY[ARRAY[1.1, 1.1, 1.1], NULL],
 			ARRAY[ARRAY[ARRAY[1.1, 1.1, 1.1], NULL], NULL],
+			ARRAY[TIMESTAMP '2024-01-01', NULL],
+			ARRAY[ARRAY[TIMESTAMP '2024-01-01', NULL], NULL],
+			ARRAY[ARRAY[ARRAY[TIMESTAMP '2024-01-01', NULL], NULL], NULL],
 			MAP(ARRAY['a', 'b'], ARRAY['c', 'd']),
 			CAST(NULL AS MAP(ARRAY(INTEGER), ARRAY(INTEGER))),
 			ROW(1, 'a', CAST('2017-07-10 01:02:03.004 UTC' AS TIMESTAMP(6) WITH TIME ZONE), ARRAY['c'])
@@ -592,6 +596,9 @@ func TestIntegrationTypeConversion(t *testing.T) {
 		&nullFloat64Slice,
 		&nullFloat64Slice2,
 		&nullFloat64Slice3,
+		&nullTimeSlice,
+		&nullTimeSlice2,
+		&nullTimeSlice3,
 		&goMap,
 		&nullMap,
 		&goRow,
@@ -655,6 +662,73 @@ func TestIntegrationNoResults(t *testing.T) {
 	}
 }
 
+func TestIntegrationArrayOfRows(t *testing.T) {
+	db := integrationOpen(t, *integrationServerFlag+"?rowValues=true")
+	defer db.Close()
+
+	var named NullSliceRowMap
+	err := db.QueryRow("SELECT ARRAY[CAST(ROW(1, 'a') AS ROW(id INTEGER, name VARCHAR)), NULL]").Scan(&named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedNamed := []NullMap{
+		{Map: map[string]interface{}{"id": int64(1), "name": "a"}, Valid: true},
+		{Map: map[string]interface{}{}, Valid: false},
+	}
+	if !named.Valid || !reflect.DeepEqual(named.SliceRowMap, expectedNamed) {
+		t.Fatalf("unexpected array of named rows: %v", named)
+	}
+
+	db = integrationOpen(t)
+	defer db.Close()
+
+	var anonymous []interface{}
+	err = db.QueryRow("SELECT ARRAY[ROW(1, 'a'), ROW(2, 'b')]").Scan(&anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedAnonymous := []interface{}{
+		[]interface{}{json.Number("1"), "a"},
+		[]interface{}{json.Number("2"), "b"},
+	}
+	if !reflect.DeepEqual(anonymous, expectedAnonymous) {
+		t.Fatalf("unexpected array of anonymous rows: %v", anonymous)
+	}
+}
+
+func TestIntegrationNullSliceBool(t *testing.T) {
+	db := integrationOpen(t)
+	defer db.Close()
+
+	var mixed NullSliceBool
+	err := db.QueryRow("SELECT ARRAY[true, NULL, false]").Scan(&mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMixed := []sql.NullBool{{Bool: true, Valid: true}, {}, {Bool: false, Valid: true}}
+	if !mixed.Valid || !reflect.DeepEqual(mixed.SliceBool, expectedMixed) {
+		t.Fatalf("unexpected array with a null element: %v", mixed)
+	}
+
+	var allNull NullSliceBool
+	err = db.QueryRow("SELECT ARRAY[CAST(NULL AS BOOLEAN), NULL]").Scan(&allNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !allNull.Valid || !reflect.DeepEqual(allNull.SliceBool, []sql.NullBool{{}, {}}) {
+		t.Fatalf("unexpected array of null elements: %v", allNull)
+	}
+
+	var null NullSliceBool
+	err = db.QueryRow("SELECT CAST(NULL AS ARRAY(BOOLEAN))").Scan(&null)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if null.Valid || len(null.SliceBool) != 0 {
+		t.Fatalf("unexpected null array: %v", null)
+	}
+}
+
 func TestIntegrationQueryParametersSelect(t *testing.T) {
 	scenarios := []struct {
 		name          string
@@ -823,12 +897,8 @@ func TestIntegrationUnsupportedHeader(t *testing.T) {
 }
 
 func TestIntegrationQueryContextCancellation(t *testing.T) {
-	err := RegisterCustomClient("uncompressed", &http.Client{Transport: &http.Transport{DisableCompression: true}})
-	if err != nil {
-		t.Fatal(err)
-	}
 	dsn := *integrationServerFlag
-	dsn += "?catalog=tpch&schema=sf100&source=cancel-test&custom_client=uncompressed"
+	dsn += "?catalog=tpch&schema=sf100&source=cancel-test&compressionDisabled=true"
 	db := integrationOpen(t, dsn)
 	defer db.Close()
 
@@ -875,7 +945,7 @@ func TestIntegrationQueryContextCancellation(t *testing.T) {
 	case <-done:
 		t.Fatal("unexpected query with cancelled context succeeded")
 		break
-	case err = <-errCh:
+	case err := <-errCh:
 		if !strings.Contains(err.Error(), "canceled") {
 			t.Fatal("expected err to be canceled but got:", err)
 		}
@@ -1068,6 +1138,26 @@ func TestIntegrationDayToHourIntervalMilliPrecision(t *testing.T) {
 			arg:     time.Duration(-12345678912) * time.Millisecond,
 			wantErr: true,
 		},
+		{
+			name:    "valid 1234.567891s",
+			arg:     time.Duration(1234567891) * time.Microsecond,
+			wantErr: false,
+		},
+		{
+			name:    "valid -1234.567891s",
+			arg:     time.Duration(-1234567891) * time.Microsecond,
+			wantErr: false,
+		},
+		{
+			name:    "valid 12345.678912s",
+			arg:     time.Duration(12345678912) * time.Microsecond,
+			wantErr: false,
+		},
+		{
+			name:    "invalid 0.000005s",
+			arg:     5 * time.Microsecond,
+			wantErr: true,
+		},
 		{
 			name:    "invalid max seconds (9223372036)",
 			arg:     time.Duration(math.MaxInt64) / time.Second * time.Second,
@@ -1114,6 +1204,49 @@ func TestIntegrationDayToHourIntervalMilliPrecision(t *testing.T) {
 	}
 }
 
+func TestIntegrationDayToSecondIntervalMicroPrecision(t *testing.T) {
+	db := integrationOpen(t)
+	defer db.Close()
+	tests := []struct {
+		name string
+		arg  time.Duration
+		want string
+	}{
+		{
+			name: "1234.567891s",
+			arg:  time.Duration(1234567891) * time.Microsecond,
+			want: "0 00:20:34.567",
+		},
+		{
+			name: "-1234.567891s",
+			arg:  time.Duration(-1234567891) * time.Microsecond,
+			want: "-0 00:20:34.567",
+		},
+		{
+			name: "12345.678912s",
+			arg:  time.Duration(12345678912) * time.Microsecond,
+			want: "0 03:25:45.678",
+		},
+		{
+			name: "-185145.678912s",
+			arg:  time.Duration(-185145678912) * time.Microsecond,
+			want: "-2 03:25:45.678",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			if err := db.QueryRow("SELECT ?", test.arg).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			// interval day to second has millisecond precision, so the microseconds are truncated
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
 func TestIntegrationLargeQuery(t *testing.T) {
 	version, err := strconv.Atoi(*trinoImageTagFlag)
 	if (err != nil && *trinoImageTagFlag != "latest") || (err == nil && version < 418) {
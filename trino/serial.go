@@ -15,9 +15,11 @@
 package trino
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
@@ -33,6 +35,7 @@ func (e UnsupportedArgError) Error() string {
 }
 
 // Numeric is a string representation of a number, such as "10", "5.5" or in scientific form
+// such as "1.5e10", which is serialized in decimal notation.
 // If another string format is used it will error to serialise
 type Numeric string
 
@@ -95,9 +98,75 @@ func Timestamp(year int,
 	return trinoTimestamp(time.Date(year, month, day, hour, minute, second, nanosecond, time.UTC))
 }
 
+// trinoRow represents a Row type in Trino.
+type trinoRow []interface{}
+
+// Row creates a representation of a Trino Row type, with fields of any supported type.
+func Row(fields ...interface{}) trinoRow {
+	return trinoRow(fields)
+}
+
+// trinoMap represents a Map type in Trino.
+type trinoMap struct {
+	keys   interface{}
+	values interface{}
+}
+
+// TrinoMap creates a representation of a Trino Map type from two slices of the same length,
+// one with the keys and the other with the values, of any supported type.
+func TrinoMap(keys, values interface{}) trinoMap {
+	return trinoMap{keys, values}
+}
+
+// MapOf creates a representation of a Trino Map type from alternating keys and values,
+// like MapOf("a", 1, "b", 2). All keys must have the same type and cannot be nil, and all
+// non-nil values must have the same type. Nil values are passed as NULL.
+func MapOf(pairs ...interface{}) (trinoMap, error) {
+	if len(pairs)%2 != 0 {
+		return trinoMap{}, fmt.Errorf("trino: map needs an even number of keys and values, got %d", len(pairs))
+	}
+	keys := make([]interface{}, 0, len(pairs)/2)
+	values := make([]interface{}, 0, len(pairs)/2)
+	var keyType, valueType reflect.Type
+	for i := 0; i < len(pairs); i += 2 {
+		key, value := pairs[i], pairs[i+1]
+		if key == nil {
+			return trinoMap{}, fmt.Errorf("trino: map key %d is nil", i/2)
+		}
+		if keyType == nil {
+			keyType = reflect.TypeOf(key)
+		} else if t := reflect.TypeOf(key); t != keyType {
+			return trinoMap{}, fmt.Errorf("trino: map key %d has type %s, expected %s", i/2, t, keyType)
+		}
+		if value != nil {
+			if valueType == nil {
+				valueType = reflect.TypeOf(value)
+			} else if t := reflect.TypeOf(value); t != valueType {
+				return trinoMap{}, fmt.Errorf("trino: map value %d has type %s, expected %s", i/2, t, valueType)
+			}
+		}
+		for _, v := range []interface{}{key, value} {
+			if _, err := Serial(v); err != nil {
+				return trinoMap{}, err
+			}
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return trinoMap{keys, values}, nil
+}
+
 // Serial converts any supported value to its equivalent string for as a Trino parameter
 // See https://trino.io/docs/current/language/types.html
 func Serial(v interface{}) (string, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := callValuer(valuer)
+		if err != nil {
+			return "", err
+		}
+		v = value
+	}
+
 	switch x := v.(type) {
 	case nil:
 		return "NULL", nil
@@ -134,7 +203,18 @@ func Serial(v interface{}) (string, error) {
 		if _, err := strconv.ParseFloat(string(x), 64); err != nil {
 			return "", err
 		}
-		return string(x), nil
+		// scientific notation is not a valid literal in all contexts, so normalize it to decimal notation
+		return decimalNotation(string(x)), nil
+
+	case *big.Int:
+		if x == nil {
+			return "NULL", nil
+		}
+		digits := x.String()
+		if len(strings.TrimPrefix(digits, "-")) > maxDecimalPrecision {
+			return "", fmt.Errorf("trino: integer %s has more than %d digits and does not fit in a decimal", digits, maxDecimalPrecision)
+		}
+		return "DECIMAL '" + digits + "'", nil
 
 		// note byte and uint are not supported, this is because byte is an alias for uint8
 		// if you were to use uint8 (as a number) it could be interpreted as a byte, so it is unsupported
@@ -166,6 +246,11 @@ func Serial(v interface{}) (string, error) {
 	case time.Duration:
 		return serialDuration(x)
 
+	case trinoRow:
+		return serialRow(x)
+	case trinoMap:
+		return serialMap(x)
+
 		// TODO - json.RawMesssage should probably be matched to 'JSON' in Trino
 	case json.RawMessage:
 		return "", UnsupportedArgError{"json.RawMessage"}
@@ -191,11 +276,25 @@ func Serial(v interface{}) (string, error) {
 		return "", UnsupportedArgError{"map"}
 	}
 
-	// TODO - consider the remaining types in https://trino.io/docs/current/language/types.html (Row, IP, ...)
+	if reflect.TypeOf(v).Kind() == reflect.Struct {
+		return serialStruct(reflect.ValueOf(v))
+	}
+
+	// TODO - consider the remaining types in https://trino.io/docs/current/language/types.html (IP, ...)
 
 	return "", UnsupportedArgError{fmt.Sprintf("%T", v)}
 }
 
+// callValuer returns the value of a driver.Valuer, such as sql.NullInt64.
+// A nil pointer to a type implementing driver.Valuer with a value receiver is NULL.
+func callValuer(valuer driver.Valuer) (driver.Value, error) {
+	if rv := reflect.ValueOf(valuer); rv.Kind() == reflect.Pointer && rv.IsNil() &&
+		rv.Type().Elem().Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem()) {
+		return nil, nil
+	}
+	return valuer.Value()
+}
+
 func serialSlice(v []interface{}) (string, error) {
 	ss := make([]string, len(v))
 
@@ -210,10 +309,113 @@ func serialSlice(v []interface{}) (string, error) {
 	return "ARRAY[" + strings.Join(ss, ", ") + "]", nil
 }
 
+func serialRow(v trinoRow) (string, error) {
+	if len(v) == 0 {
+		return "", UnsupportedArgError{"row without fields"}
+	}
+	ss := make([]string, len(v))
+	for i, x := range v {
+		s, err := Serial(x)
+		if err != nil {
+			return "", err
+		}
+		ss[i] = s
+	}
+
+	return "ROW(" + strings.Join(ss, ", ") + ")", nil
+}
+
+func serialMap(v trinoMap) (string, error) {
+	keys, values := reflect.ValueOf(v.keys), reflect.ValueOf(v.values)
+	if keys.Kind() != reflect.Slice || values.Kind() != reflect.Slice {
+		return "", UnsupportedArgError{fmt.Sprintf("map of %T to %T", v.keys, v.values)}
+	}
+	if keys.Len() != values.Len() {
+		return "", fmt.Errorf("trino: map has %d keys but %d values", keys.Len(), values.Len())
+	}
+	k, err := Serial(v.keys)
+	if err != nil {
+		return "", err
+	}
+	vs, err := Serial(v.values)
+	if err != nil {
+		return "", err
+	}
+
+	return "MAP(" + k + ", " + vs + ")", nil
+}
+
+// decimalNotation rewrites a number in scientific notation, such as "-1.25e3", in decimal notation
+// by moving the decimal point, keeping all its digits. Other numbers are returned as they are.
+func decimalNotation(s string) string {
+	mantissa, exponent, ok := strings.Cut(strings.ToLower(s), "e")
+	if !ok || strings.HasPrefix(mantissa, "0x") {
+		return s
+	}
+	exp, err := strconv.Atoi(exponent)
+	if err != nil {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(mantissa, "-") || strings.HasPrefix(mantissa, "+") {
+		sign, mantissa = strings.TrimPrefix(mantissa[:1], "+"), mantissa[1:]
+	}
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	digits := integer + fraction
+	point := len(integer) + exp
+	switch {
+	case point <= 0:
+		integer, fraction = "0", strings.Repeat("0", -point)+digits
+	case point >= len(digits):
+		integer, fraction = digits+strings.Repeat("0", point-len(digits)), ""
+	default:
+		integer, fraction = digits[:point], digits[point:]
+	}
+	integer = strings.TrimLeft(integer, "0")
+	if integer == "" {
+		integer = "0"
+	}
+	if fraction == "" {
+		return sign + integer
+	}
+	return sign + integer + "." + fraction
+}
+
+// serialStruct serializes the exported fields of a struct, in order, as a ROW constructor.
+// Fields tagged with `trino:"-"` are skipped. Other tags are rejected, since a ROW
+// constructor creates an anonymous row and cannot set the names of its fields.
+func serialStruct(v reflect.Value) (string, error) {
+	var ss []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch tag := field.Tag.Get("trino"); tag {
+		case "-":
+			continue
+		case "":
+		default:
+			return "", UnsupportedArgError{fmt.Sprintf("%s with field %s tagged %q, row field names cannot be set in a ROW constructor", v.Type(), field.Name, tag)}
+		}
+		s, err := Serial(v.Field(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		ss = append(ss, s)
+	}
+	if len(ss) == 0 {
+		return "", UnsupportedArgError{fmt.Sprintf("%s without exported fields", v.Type())}
+	}
+
+	return "ROW(" + strings.Join(ss, ", ") + ")", nil
+}
+
 const (
 	// For seconds with milliseconds there is a maximum length of 10 digits
 	// or 11 characters with the dot and 12 characters with the minus sign and dot
 	maxIntervalStrLenWithDot = 11 // 123456789.1 and 12345678.91 are valid
+	maxDecimalPrecision      = 38
 )
 
 func serialDuration(dur time.Duration) (string, error) {
@@ -226,8 +428,10 @@ func serialDuration(dur time.Duration) (string, error) {
 		return serialSecondsInterval(dur)
 	case dur%time.Millisecond == 0:
 		return serialMillisecondsInterval(dur)
+	case dur%time.Microsecond == 0:
+		return serialMicrosecondsInterval(dur)
 	default:
-		return "", fmt.Errorf("trino: duration %v is not a multiple of hours, minutes, seconds or milliseconds", dur)
+		return "", fmt.Errorf("trino: duration %v is not a multiple of hours, minutes, seconds, milliseconds or microseconds", dur)
 	}
 }
 
@@ -257,3 +461,25 @@ func serialMillisecondsInterval(dur time.Duration) (string, error) {
 	}
 	return "INTERVAL '" + intervalNr + "' SECOND", nil
 }
+
+// serialMicrosecondsInterval serializes durations with microseconds, which Trino truncates
+// to the millisecond precision of the interval day to second type.
+func serialMicrosecondsInterval(dur time.Duration) (string, error) {
+	abs := dur.Abs()
+	if abs < time.Millisecond {
+		return "", fmt.Errorf("trino: duration %v is below the millisecond precision of interval day to second type", dur)
+	}
+	sign := ""
+	if dur < 0 {
+		sign = "-"
+	}
+	seconds := int64(abs / time.Second)
+	micros := abs.Microseconds() % 1000000
+	intervalNr := fmt.Sprintf("%d.%06d", seconds, micros)
+	if len(intervalNr) <= maxIntervalStrLenWithDot {
+		return "INTERVAL '" + sign + intervalNr + "' SECOND", nil
+	}
+	// the seconds are too long for a single field, so split them into days, hours and minutes
+	return fmt.Sprintf("INTERVAL %s'%d %02d:%02d:%02d.%06d' DAY TO SECOND",
+		sign, seconds/86400, seconds/3600%24, seconds/60%60, seconds%60, micros), nil
+}
@@ -15,7 +15,9 @@
 package trino
 
 import (
+	"database/sql"
 	"math"
+	"math/big"
 	"testing"
 	"time"
 
@@ -106,11 +108,51 @@ func TestSerial(t *testing.T) {
 			value:          Numeric("10"),
 			expectedSerial: "10",
 		},
+		{
+			name:           "Numeric in scientific notation",
+			value:          Numeric("1.5e10"),
+			expectedSerial: "15000000000",
+		},
+		{
+			name:           "negative Numeric in scientific notation",
+			value:          Numeric("-3.2e-4"),
+			expectedSerial: "-0.00032",
+		},
+		{
+			name:           "Numeric in scientific notation with more digits than a float64",
+			value:          Numeric("1.2345678901234567890123E+20"),
+			expectedSerial: "123456789012345678901.23",
+		},
+		{
+			name:           "Numeric in scientific notation with trailing zeros",
+			value:          Numeric("+0.0150E2"),
+			expectedSerial: "1.50",
+		},
 		{
 			name:          "invalid Numeric",
 			value:         Numeric("not-a-number"),
 			expectedError: true,
 		},
+		{
+			name:           "big.Int",
+			value:          new(big.Int).Lsh(big.NewInt(1), 100),
+			expectedSerial: "DECIMAL '1267650600228229401496703205376'",
+		},
+		{
+			name:           "negative big.Int",
+			value:          big.NewInt(-42),
+			expectedSerial: "DECIMAL '-42'",
+		},
+		{
+			name:           "nil big.Int",
+			value:          (*big.Int)(nil),
+			expectedSerial: "NULL",
+		},
+		{
+			name:          "big.Int with more than 38 digits",
+			value:         new(big.Int).Exp(big.NewInt(10), big.NewInt(38), nil),
+			expectedError: true,
+		},
 		{
 			name:           "bool true",
 			value:          true,
@@ -171,6 +213,31 @@ func TestSerial(t *testing.T) {
 			value:          -(10*time.Second + 5*time.Millisecond),
 			expectedSerial: "INTERVAL '-10.005' SECOND",
 		},
+		{
+			name:           "duration with microseconds",
+			value:          10*time.Second + 5*time.Microsecond,
+			expectedSerial: "INTERVAL '10.000005' SECOND",
+		},
+		{
+			name:           "duration with microseconds and negative value",
+			value:          -(10*time.Second + 5*time.Microsecond),
+			expectedSerial: "INTERVAL '-10.000005' SECOND",
+		},
+		{
+			name:           "sub-second duration with microseconds and negative value",
+			value:          -(5*time.Millisecond + 5*time.Microsecond),
+			expectedSerial: "INTERVAL '-0.005005' SECOND",
+		},
+		{
+			name:          "sub-millisecond duration",
+			value:         -5 * time.Microsecond,
+			expectedError: true,
+		},
+		{
+			name:          "duration with nanoseconds",
+			value:         10*time.Second + 5*time.Nanosecond,
+			expectedError: true,
+		},
 		{
 			name:           "minute duration",
 			value:          10 * time.Minute,
@@ -241,6 +308,21 @@ func TestSerial(t *testing.T) {
 			value:          -999999999*time.Second - 900*time.Millisecond,
 			expectedSerial: "INTERVAL '-999999999.9' SECOND",
 		},
+		{
+			name:           "max allowed second with microseconds duration",
+			value:          9999*time.Second + 999999*time.Microsecond,
+			expectedSerial: "INTERVAL '9999.999999' SECOND",
+		},
+		{
+			name:           "day to second microsecond duration",
+			value:          12345*time.Second + 678912*time.Microsecond,
+			expectedSerial: "INTERVAL '0 03:25:45.678912' DAY TO SECOND",
+		},
+		{
+			name:           "day to second microsecond duration and negative value",
+			value:          -(2*24*time.Hour + 12345*time.Second + 678912*time.Microsecond),
+			expectedSerial: "INTERVAL -'2 03:25:45.678912' DAY TO SECOND",
+		},
 		{
 			name:           "nil",
 			value:          nil,
@@ -261,6 +343,255 @@ func TestSerial(t *testing.T) {
 			value:          []interface{}{},
 			expectedSerial: "ARRAY[]",
 		},
+		{
+			name:           "slice of time.Time",
+			value:          []time.Time{time.Date(2017, 7, 10, 11, 34, 25, 0, time.UTC), time.Date(2017, 7, 11, 0, 0, 0, 0, paris)},
+			expectedSerial: "ARRAY[TIMESTAMP '2017-07-10 11:34:25 Z', TIMESTAMP '2017-07-11 00:00:00 +02:00']",
+		},
+		{
+			name:           "slice of bool",
+			value:          []bool{true, false},
+			expectedSerial: "ARRAY[true, false]",
+		},
+		{
+			name:           "slice of Numeric",
+			value:          []Numeric{"1.5", "1e3"},
+			expectedSerial: "ARRAY[1.5, 1000]",
+		},
+		{
+			name:           "slice of Date",
+			value:          []trinoDate{Date(2017, 7, 10)},
+			expectedSerial: "ARRAY[DATE '2017-07-10']",
+		},
+		{
+			name:           "slice of slices of time.Duration",
+			value:          [][]time.Duration{{time.Hour}, {}},
+			expectedSerial: "ARRAY[ARRAY[INTERVAL '1' HOUR], ARRAY[]]",
+		},
+		{
+			name:          "slice of float64",
+			value:         []float64{1.5},
+			expectedError: true,
+		},
+		{
+			name:          "typed nil slice of time.Time",
+			value:         []time.Time(nil),
+			expectedError: true,
+		},
+		{
+			name: "struct",
+			value: struct {
+				A       int
+				B       string
+				C       []int
+				hidden  int
+				Skipped string `trino:"-"`
+			}{1, "x", []int{2}, 3, "y"},
+			expectedSerial: "ROW(1, 'x', ARRAY[2])",
+		},
+		{
+			name: "nested struct",
+			value: struct {
+				A struct{ B bool }
+				T time.Time
+			}{struct{ B bool }{true}, time.Date(2017, 7, 10, 11, 34, 25, 0, time.UTC)},
+			expectedSerial: "ROW(ROW(true), TIMESTAMP '2017-07-10 11:34:25 Z')",
+		},
+		{
+			name:          "struct without exported fields",
+			value:         struct{ a int }{1},
+			expectedError: true,
+		},
+		{
+			name:          "struct with unsupported field",
+			value:         struct{ A float64 }{1},
+			expectedError: true,
+		},
+		{
+			name: "struct with a field name tag",
+			value: struct {
+				A int `trino:"a"`
+			}{1},
+			expectedError: true,
+		},
+		{
+			name:           "row",
+			value:          Row(1, "x", Date(2017, 7, 10), []string{"a"}),
+			expectedSerial: "ROW(1, 'x', DATE '2017-07-10', ARRAY['a'])",
+		},
+		{
+			name:           "nested row",
+			value:          Row(Row(true), nil),
+			expectedSerial: "ROW(ROW(true), NULL)",
+		},
+		{
+			name:          "empty row",
+			value:         Row(),
+			expectedError: true,
+		},
+		{
+			name:          "row with unsupported field",
+			value:         Row(1.5),
+			expectedError: true,
+		},
+		{
+			name:           "map",
+			value:          TrinoMap([]string{"a", "b"}, []int{1, 2}),
+			expectedSerial: "MAP(ARRAY['a', 'b'], ARRAY[1, 2])",
+		},
+		{
+			name:           "empty map",
+			value:          TrinoMap([]string{}, []int{}),
+			expectedSerial: "MAP(ARRAY[], ARRAY[])",
+		},
+		{
+			name:           "map of rows",
+			value:          TrinoMap([]int{1}, []interface{}{Row("x", 2)}),
+			expectedSerial: "MAP(ARRAY[1], ARRAY[ROW('x', 2)])",
+		},
+		{
+			name:          "map with mismatched lengths",
+			value:         TrinoMap([]string{"a", "b"}, []int{1}),
+			expectedError: true,
+		},
+		{
+			name:          "map without slices",
+			value:         TrinoMap("a", 1),
+			expectedError: true,
+		},
+		{
+			name:          "map with nil keys",
+			value:         TrinoMap([]string(nil), []int(nil)),
+			expectedError: true,
+		},
+		{
+			name:           "null sql.NullInt64",
+			value:          sql.NullInt64{},
+			expectedSerial: "NULL",
+		},
+		{
+			name:           "valid sql.NullInt64",
+			value:          sql.NullInt64{Int64: 42, Valid: true},
+			expectedSerial: "42",
+		},
+		{
+			name:           "valid sql.NullInt32",
+			value:          sql.NullInt32{Int32: 42, Valid: true},
+			expectedSerial: "42",
+		},
+		{
+			name:           "valid sql.NullInt16",
+			value:          sql.NullInt16{Int16: 42, Valid: true},
+			expectedSerial: "42",
+		},
+		{
+			name:           "valid sql.NullByte",
+			value:          sql.NullByte{Byte: 42, Valid: true},
+			expectedSerial: "42",
+		},
+		{
+			name:           "valid sql.NullString",
+			value:          sql.NullString{String: "x", Valid: true},
+			expectedSerial: "'x'",
+		},
+		{
+			name:           "valid sql.NullBool",
+			value:          sql.NullBool{Bool: true, Valid: true},
+			expectedSerial: "true",
+		},
+		{
+			name:           "valid sql.NullTime",
+			value:          sql.NullTime{Time: time.Date(2017, 7, 10, 11, 34, 25, 0, time.UTC), Valid: true},
+			expectedSerial: "TIMESTAMP '2017-07-10 11:34:25 Z'",
+		},
+		{
+			name:           "null sql.NullFloat64",
+			value:          sql.NullFloat64{},
+			expectedSerial: "NULL",
+		},
+		{
+			name:          "valid sql.NullFloat64",
+			value:         sql.NullFloat64{Float64: 1.5, Valid: true},
+			expectedError: true,
+		},
+		{
+			name:           "valid sql.Null[string]",
+			value:          sql.Null[string]{V: "x", Valid: true},
+			expectedSerial: "'x'",
+		},
+		{
+			name:           "nil *sql.NullString",
+			value:          (*sql.NullString)(nil),
+			expectedSerial: "NULL",
+		},
+		{
+			name:           "slice of sql.NullString",
+			value:          []sql.NullString{{String: "x", Valid: true}, {}},
+			expectedSerial: "ARRAY['x', NULL]",
+		},
+		{
+			name:           "null NullTime",
+			value:          NullTime{},
+			expectedSerial: "NULL",
+		},
+		{
+			name:           "valid NullTime",
+			value:          NullTime{Time: time.Date(2017, 7, 10, 11, 34, 25, 0, time.UTC), Valid: true},
+			expectedSerial: "TIMESTAMP '2017-07-10 11:34:25 Z'",
+		},
+		{
+			name:           "null NullSliceString",
+			value:          NullSliceString{},
+			expectedSerial: "NULL",
+		},
+		{
+			name:           "valid NullSliceString",
+			value:          NullSliceString{SliceString: []sql.NullString{{String: "x", Valid: true}, {}}, Valid: true},
+			expectedSerial: "ARRAY['x', NULL]",
+		},
+		{
+			name:           "valid NullSlice2Int64",
+			value:          NullSlice2Int64{Slice2Int64: [][]sql.NullInt64{{{Int64: 1, Valid: true}}, {}}, Valid: true},
+			expectedSerial: "ARRAY[ARRAY[1], ARRAY[]]",
+		},
+		{
+			name:           "valid NullSlice3Int32",
+			value:          NullSlice3Int32{Slice3Int32: [][][]sql.NullInt32{{{{Int32: 1, Valid: true}, {}}}}, Valid: true},
+			expectedSerial: "ARRAY[ARRAY[ARRAY[1, NULL]]]",
+		},
+		{
+			name:           "valid NullSliceTime",
+			value:          NullSliceTime{SliceTime: []NullTime{{Time: time.Date(2017, 7, 10, 11, 34, 25, 0, time.UTC), Valid: true}, {}}, Valid: true},
+			expectedSerial: "ARRAY[TIMESTAMP '2017-07-10 11:34:25 Z', NULL]",
+		},
+		{
+			name:           "null NullMap",
+			value:          NullMap{},
+			expectedSerial: "NULL",
+		},
+		{
+			name:           "valid NullMap",
+			value:          NullMap{Map: map[string]interface{}{"b": "y", "a": "x"}, Valid: true},
+			expectedSerial: "MAP(ARRAY['a', 'b'], ARRAY['x', 'y'])",
+		},
+		{
+			name:           "valid NullStringMap",
+			value:          NullStringMap{Map: map[string]string{"b": "y", "a": "x"}, Valid: true},
+			expectedSerial: "MAP(ARRAY['a', 'b'], ARRAY['x', 'y'])",
+		},
+		{
+			name:           "valid NullSliceMap",
+			value:          NullSliceMap{SliceMap: []NullMap{{Map: map[string]interface{}{"a": "x"}, Valid: true}, {}}, Valid: true},
+			expectedSerial: "ARRAY[MAP(ARRAY['a'], ARRAY['x']), NULL]",
+		},
+		{
+			name: "struct with sql.NullInt64",
+			value: struct {
+				A sql.NullInt64
+				B sql.NullInt64
+			}{sql.NullInt64{Int64: 1, Valid: true}, sql.NullInt64{}},
+			expectedSerial: "ROW(1, NULL)",
+		},
 		{
 			name:          "invalid slice contents",
 			value:         []interface{}{1, byte('a')},
@@ -290,3 +621,41 @@ func TestSerial(t *testing.T) {
 		})
 	}
 }
+
+func TestMapOf(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		pairs          []interface{}
+		expectedSerial string
+	}{
+		{"empty map", nil, "MAP(ARRAY[], ARRAY[])"},
+		{"map", []interface{}{"a", 1, "b", 2}, "MAP(ARRAY['a', 'b'], ARRAY[1, 2])"},
+		{"map with NULL values", []interface{}{"a", nil, "b", Date(2017, 7, 10)}, "MAP(ARRAY['a', 'b'], ARRAY[NULL, DATE '2017-07-10'])"},
+		{"map of rows", []interface{}{1, Row("x", 2)}, "MAP(ARRAY[1], ARRAY[ROW('x', 2)])"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := MapOf(tc.pairs...)
+			require.NoError(t, err)
+			s, err := Serial(m)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedSerial, s)
+		})
+	}
+
+	for _, tc := range []struct {
+		name  string
+		pairs []interface{}
+	}{
+		{"odd number of arguments", []interface{}{"a", 1, "b"}},
+		{"nil key", []interface{}{nil, 1}},
+		{"inconsistent keys", []interface{}{"a", 1, 2, 2}},
+		{"inconsistent values", []interface{}{"a", 1, "b", "x"}},
+		{"unsupported key", []interface{}{1.5, 1}},
+		{"unsupported value", []interface{}{"a", byte('a')}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := MapOf(tc.pairs...)
+			require.Error(t, err)
+		})
+	}
+}
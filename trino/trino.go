@@ -51,20 +51,29 @@
 package trino
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
+	"math/big"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
@@ -76,6 +85,9 @@ import (
 	"github.com/jcmturner/gokrb5/v8/config"
 	"github.com/jcmturner/gokrb5/v8/keytab"
 	"github.com/jcmturner/gokrb5/v8/spnego"
+	"golang.org/x/net/http2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
 func init() {
@@ -101,6 +113,13 @@ var (
 	// ErrInvalidResponseType indicates that the server returned an invalid type definition.
 	ErrInvalidResponseType = errors.New("trino: server response contains an invalid type")
 
+	// ErrLockingNotSupported indicates that a query failed because it has a FOR UPDATE or FOR SHARE
+	// locking clause, which Trino doesn't support. The ErrQueryFailed returned for such queries wraps it.
+	ErrLockingNotSupported = errors.New("trino: FOR UPDATE and FOR SHARE locking clauses are not supported")
+
+	// ErrResponseTooLarge indicates that the server response body exceeds the configured maximum size.
+	ErrResponseTooLarge = errors.New("trino: server response body is too large")
+
 	// ErrInvalidProgressCallbackHeader indicates that server did not get valid headers for progress callback
 	ErrInvalidProgressCallbackHeader = errors.New("trino: both " + trinoProgressCallbackParam + " and " + trinoProgressCallbackPeriodParam + " must be set when using progress callback")
 )
@@ -113,6 +132,12 @@ const (
 
 	trinoUserHeader            = trinoHeaderPrefix + `User`
 	trinoSourceHeader          = trinoHeaderPrefix + `Source`
+	trinoClientInfoHeader      = trinoHeaderPrefix + `Client-Info`
+	trinoLanguageHeader        = trinoHeaderPrefix + `Language`
+	trinoTimeZoneHeader        = trinoHeaderPrefix + `Time-Zone`
+	trinoTraceTokenHeader      = trinoHeaderPrefix + `Trace-Token`
+	trinoClientTagsHeader      = trinoHeaderPrefix + `Client-Tags`
+	trinoClientRequestIDHeader = trinoHeaderPrefix + `Client-Request-ID`
 	trinoCatalogHeader         = trinoHeaderPrefix + `Catalog`
 	trinoSchemaHeader          = trinoHeaderPrefix + `Schema`
 	trinoSessionHeader         = trinoHeaderPrefix + `Session`
@@ -123,6 +148,11 @@ const (
 	trinoClearSessionHeader    = trinoHeaderPrefix + `Clear-Session`
 	trinoSetRoleHeader         = trinoHeaderPrefix + `Set-Role`
 	trinoExtraCredentialHeader = trinoHeaderPrefix + `Extra-Credential`
+	trinoRoleHeader            = trinoHeaderPrefix + `Role`
+
+	trinoTransactionHeader        = trinoHeaderPrefix + `Transaction-Id`
+	trinoStartedTransactionHeader = trinoHeaderPrefix + `Started-Transaction-Id`
+	trinoClearTransactionHeader   = trinoHeaderPrefix + `Clear-Transaction-Id`
 
 	trinoProgressCallbackParam       = trinoHeaderPrefix + `Progress-Callback`
 	trinoProgressCallbackPeriodParam = trinoHeaderPrefix + `Progress-Callback-Period`
@@ -131,6 +161,7 @@ const (
 	trinoDeallocatedPrepareHeader = trinoHeaderPrefix + `Deallocated-Prepare`
 
 	authorizationHeader = "Authorization"
+	userAgentHeader     = "User-Agent"
 
 	kerberosEnabledConfig            = "KerberosEnabled"
 	kerberosKeytabPathConfig         = "KerberosKeytabPath"
@@ -138,11 +169,38 @@ const (
 	kerberosRealmConfig              = "KerberosRealm"
 	kerberosConfigPathConfig         = "KerberosConfigPath"
 	kerberosRemoteServiceNameConfig  = "KerberosRemoteServiceName"
+	kerberosServiceKDCHostnameConfig = "KerberosServiceKDCHostname"
+	oauth2TokenURLConfig             = "oauth2TokenURL"
+	oauth2ClientIDConfig             = "oauth2ClientID"
+	oauth2ClientSecretConfig         = "oauth2ClientSecret"
+	oauth2ScopesConfig               = "oauth2Scopes"
 	sslCertPathConfig                = "SSLCertPath"
 	sslCertConfig                    = "SSLCert"
+	sslClientCertPathConfig          = "SSLClientCertPath"
+	sslClientKeyPathConfig           = "SSLClientKeyPath"
+	sslClientCertConfig              = "SSLClientCert"
+	sslClientKeyConfig               = "SSLClientKey"
+	hostVerificationConfig           = "hostVerification"
 	accessTokenConfig                = "accessToken"
 	explicitPrepareConfig            = "explicitPrepare"
 	forwardAuthorizationHeaderConfig = "forwardAuthorizationHeader"
+	maxResponseBodySizeConfig        = "maxResponseBodySize"
+	readBufferSizeConfig             = "readBufferSize"
+	serverStartupRetriesConfig       = "serverStartupRetries"
+	compressionDisabledConfig        = "compressionDisabled"
+	http2Config                      = "http2"
+	connectTimeoutConfig             = "connectTimeout"
+	queryTimeoutConfig               = "queryTimeout"
+	socketTimeoutConfig              = "socketTimeout"
+	keepAliveConfig                  = "keepAlive"
+	keepAliveIntervalConfig          = "keepAliveInterval"
+	userAgentConfig                  = "userAgent"
+	traceQueryTextConfig             = "traceQueryText"
+	enableQueryInfoConfig            = "enableQueryInfo"
+	localeConfig                     = "locale"
+	timeZoneConfig                   = "timeZone"
+	traceTokenConfig                 = "traceToken"
+	rowValuesConfig                  = "rowValues"
 
 	mapKeySeparator   = ":"
 	mapEntrySeparator = ";"
@@ -165,16 +223,152 @@ func (d *Driver) Open(name string) (driver.Conn, error) {
 	return newConn(name)
 }
 
-var _ driver.Driver = &Driver{}
+// OpenConnector implements the driver.DriverContext interface, so that the connections of a
+// sql.DB opened with sql.Open share the state of its connector, like OAuth2 tokens.
+func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	return &connector{dsn: name}, nil
+}
+
+var (
+	_ driver.Driver        = &Driver{}
+	_ driver.DriverContext = &Driver{}
+)
+
+// Option configures a connector created by NewConnector.
+type Option func(*connector)
+
+// WithLogger sets the logger used by connections of the connector,
+// overriding the Logger set in the Config.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *connector) {
+		c.logger = logger
+	}
+}
+
+type connector struct {
+	dsn        string
+	logger     *slog.Logger
+	middleware []func(http.RoundTripper) http.RoundTripper
+	metrics    ConnMetricsCallback
+
+	// the OAuth2 token source shared by the connections, so that they reuse tokens until they expire
+	mu           sync.Mutex
+	oauth2Source oauth2.TokenSource
+}
+
+var _ driver.Connector = &connector{}
+
+// NewConnector returns a connector for the given configuration, to be used
+// with sql.OpenDB. Unlike a DSN string, it supports options that can't be
+// encoded in the DSN, like the Logger.
+func NewConnector(config *Config, opts ...Option) (driver.Connector, error) {
+	dsn, err := config.FormatDSN()
+	if err != nil {
+		return nil, err
+	}
+	c := &connector{dsn: dsn, logger: config.Logger, middleware: config.Middleware, metrics: config.ConnMetrics}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Open opens a database for the given configuration, with its connection pool
+// configured by the MaxIdleConns, MaxOpenConns and ConnMaxLifetime fields.
+//
+// If PrewarmConnections is set, Open also pings the server with that many concurrent
+// connections in the background, so that the first queries don't wait for them to be
+// established. Failures are ignored, and connections beyond MaxIdleConns are closed
+// once the pings are done. The pings are cancelled after the ConnectTimeout, or the
+// DefaultQueryTimeout if it isn't set.
+func Open(config *Config, opts ...Option) (*sql.DB, error) {
+	if config.MaxIdleConns < 0 || config.MaxOpenConns < 0 || config.ConnMaxLifetime < 0 || config.PrewarmConnections < 0 {
+		return nil, fmt.Errorf("trino: client configuration error, connection pool limits cannot be negative")
+	}
+	c, err := NewConnector(config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(c)
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	if config.PrewarmConnections > 0 {
+		timeout := config.ConnectTimeout
+		if timeout <= 0 {
+			timeout = DefaultQueryTimeout
+		}
+		go prewarm(db, config.PrewarmConnections, timeout)
+	}
+	return db, nil
+}
+
+func prewarm(db *sql.DB, n int, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = db.PingContext(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Connect implements the driver.Connector interface.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := newConn(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn.logger = c.logger
+	conn.metrics = c.metrics
+	if conn.oauth2Transport != nil {
+		c.mu.Lock()
+		if c.oauth2Source == nil {
+			c.oauth2Source = conn.oauth2Transport.Source
+		} else {
+			conn.oauth2Transport.Source = c.oauth2Source
+		}
+		c.mu.Unlock()
+	}
+	if len(c.middleware) > 0 {
+		transport := conn.httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		for i := len(c.middleware) - 1; i >= 0; i-- {
+			transport = c.middleware[i](transport)
+		}
+		conn.httpClient.Transport = transport
+	}
+	return conn, nil
+}
+
+// Driver implements the driver.Connector interface.
+func (c *connector) Driver() driver.Driver {
+	return &Driver{}
+}
 
 // Config is a configuration that can be encoded to a DSN string.
 type Config struct {
 	ServerURI                  string            // URI of the Trino server, e.g. http://user@localhost:8080
 	Source                     string            // Source of the connection (optional)
+	UserAgent                  string            // User-Agent header sent with every request (optional, default is trino-go-client/<version>)
+	ApplicationName            string            // Name of the application, sent as client info (optional)
+	Locale                     string            // Locale of the session as an IETF language tag, e.g. en-US, used by functions like format_datetime (optional, default is the server locale)
+	TimeZone                   string            // Time zone of the session, e.g. America/New_York, used by current_date and other time zone aware functions (optional, default is the server time zone)
+	QueryTraceToken            string            // Trace token sent with every query to correlate it in the server logs, overridden by WithTraceToken (optional)
 	Catalog                    string            // Catalog (optional)
 	Schema                     string            // Schema (optional)
 	SessionProperties          map[string]string // Session properties (optional)
 	ExtraCredentials           map[string]string // Extra credentials (optional)
+	Roles                      map[string]string // Roles by catalog, each one either ROLE{name}, ALL or NONE (optional)
+	ForwardedHeaders           map[string]string // HTTP headers added to every request, like X-Forwarded-For or headers required by an API gateway (optional)
 	CustomClientName           string            // Custom client name (optional)
 	KerberosEnabled            string            // KerberosEnabled (optional, default is false)
 	KerberosKeytabPath         string            // Kerberos Keytab Path (optional)
@@ -182,10 +376,91 @@ type Config struct {
 	KerberosRemoteServiceName  string            // Trino coordinator Kerberos service name (optional)
 	KerberosRealm              string            // The Kerberos Realm (optional)
 	KerberosConfigPath         string            // The krb5 config path (optional)
+	KerberosServiceKDCHostname string            // KDC hostname, with an optional port, overriding the KDCs of the krb5 config for the Kerberos realm (optional)
 	SSLCertPath                string            // The SSL cert path for TLS verification (optional)
 	SSLCert                    string            // The SSL cert for TLS verification (optional)
+	SSLClientCertPath          string            // The client certificate path for mutual TLS authentication (optional)
+	SSLClientKeyPath           string            // The client private key path for mutual TLS authentication (optional)
+	SSLClientCert              string            // The client certificate for mutual TLS authentication (optional)
+	SSLClientKey               string            // The client private key for mutual TLS authentication (optional)
+	HostVerification           *bool             // Verify the server certificate and host name, set to false only for development clusters (optional, default is true)
 	AccessToken                string            // An access token (JWT) for authentication (optional)
+	OAuth2TokenURL             string            // URL of the token endpoint of an OAuth2 client credentials grant, to authenticate with a bearer token (optional)
+	OAuth2ClientID             string            // Client ID of the OAuth2 client credentials grant (optional)
+	OAuth2ClientSecret         string            // Client secret of the OAuth2 client credentials grant (optional)
+	OAuth2Scopes               []string          // Scopes requested with the OAuth2 client credentials grant (optional)
+	ExplicitPrepare            bool              // Send queries with parameters as prepared statements in request headers and run them with EXECUTE, instead of using EXECUTE IMMEDIATE (optional, default is false)
 	ForwardAuthorizationHeader bool              // Allow forwarding the `accessToken` named query parameter in the authorization header, overwriting the `AccessToken` option, if set (optional)
+	CompressionDisabled        bool              // Disable HTTP response compression (optional, default is false)
+	HTTP2                      bool              // Use HTTP/2 with prior knowledge (h2c) for unencrypted connections, HTTPS negotiates HTTP/2 regardless (optional, default is false)
+	MaxResponseBodySize        int64             // Maximum size in bytes of a response body read from the server (optional, default is 0 for unlimited)
+	ReadBufferSize             int               // Size in bytes of the buffer used to read response bodies while decoding them (optional, default is 0 for no extra buffering)
+	ServerStartupRetries       int               // Maximum number of times a query failing because the server is starting up is resubmitted, negative to disable (optional, default is 10)
+	ConnectTimeout             time.Duration     // Timeout for establishing a connection to the server, including the TLS handshake (optional, default is 0 for no timeout)
+	QueryTimeout               time.Duration     // Timeout for queries executed with a context without a deadline (optional, default is DefaultQueryTimeout)
+	SocketTimeout              time.Duration     // Timeout for waiting for the response headers of each request after sending it (optional, default is 0 for no timeout)
+	KeepAlive                  time.Duration     // Idle time before sending TCP keep-alive probes, negative to disable them (optional, default is 30s)
+	KeepAliveInterval          time.Duration     // Interval between TCP keep-alive probes, ignored before Go 1.23 (optional, default is KeepAlive)
+	TraceQueryText             bool              // Include the query text, truncated to 4096 characters, in ErrQueryFailed messages (optional, default is false)
+	EnableQueryInfo            bool              // Record the URI of the query info of queries, returned by QueryInfoURIContext (optional, default is false)
+	RowValues                  bool              // Return named rows in ARRAY(ROW) values as RowValue, like named ROW values, instead of []interface{} slices of raw values (optional, default is false)
+	Logger                     *slog.Logger      // Logger for requests, responses, retries and errors, only used by NewConnector (optional)
+	MaxIdleConns               int               // Maximum number of idle connections in the pool, only used by Open (optional, default is the database/sql default)
+	MaxOpenConns               int               // Maximum number of open connections, only used by Open (optional, default is 0 for unlimited)
+	ConnMaxLifetime            time.Duration     // Maximum time a connection may be reused, only used by Open (optional, default is 0 for no limit)
+	PrewarmConnections         int               // Number of connections to open in the background, only used by Open (optional, default is 0 for none)
+
+	// AdditionalQueryParameters are added to the DSN as they are, to set parameters that
+	// aren't modeled by Config fields yet. They never replace parameters set by other fields (optional).
+	AdditionalQueryParameters map[string]string
+
+	// Middleware wraps the transport of the HTTP client, the first one being the outermost.
+	// It is only used by NewConnector (optional).
+	Middleware []func(http.RoundTripper) http.RoundTripper
+
+	// ConnMetrics is called at the lifecycle points of queries, to record metrics.
+	// It is only used by NewConnector (optional).
+	ConnMetrics ConnMetricsCallback
+}
+
+// ConnMetricsCallback receives the lifecycle events of the queries of a connection, for example to
+// export them as metrics. Its methods are called from the goroutines running and reading queries,
+// so they must be safe for concurrent use, and should return quickly.
+type ConnMetricsCallback interface {
+	// OnQueryStart is called when Trino accepted a query and assigned it an ID.
+	OnQueryStart(queryID string)
+	// OnQueryEnd is called once all the results of a query were read, it failed, or its rows were closed,
+	// with the time since the query was submitted, and the number of rows read from the server.
+	OnQueryEnd(queryID string, duration time.Duration, rowsRead int64, err error)
+	// OnSegmentDownload is called after downloading each segment of the results of a query, with the
+	// size of the response body and the time since the request was sent. The segment type is "inline",
+	// for results returned by the coordinator in the response.
+	OnSegmentDownload(queryID string, segmentType string, bytes int64, duration time.Duration)
+}
+
+// inlineSegmentType is the segment type of results returned by the coordinator in the response.
+const inlineSegmentType = "inline"
+
+// meteredBody is a response body which reports its size once closed.
+type meteredBody struct {
+	io.ReadCloser
+	n      int64
+	closed func(n int64)
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.closed != nil {
+		b.closed(b.n)
+		b.closed = nil
+	}
+	return err
 }
 
 // FormatDSN returns a DSN string from the configuration.
@@ -203,9 +478,31 @@ func (c *Config) FormatDSN() (string, error) {
 	var credkv []string
 	if c.ExtraCredentials != nil {
 		for k, v := range c.ExtraCredentials {
+			if err := validateMapHeaderEntry("extra_credentials", k, v); err != nil {
+				return "", err
+			}
 			credkv = append(credkv, k+mapKeySeparator+v)
 		}
 	}
+	var roleskv []string
+	for catalog, role := range c.Roles {
+		if err := validateRole(catalog, role); err != nil {
+			return "", err
+		}
+		roleskv = append(roleskv, catalog+mapKeySeparator+role)
+	}
+	var headerskv []string
+	for name, value := range c.ForwardedHeaders {
+		if err := validateForwardedHeader(name); err != nil {
+			return "", err
+		}
+		if !isHeaderValue(value) {
+			// do not log value as it may contain sensitive information
+			return "", fmt.Errorf("trino: client configuration error, the value of the forwarded header %s is empty or contains control characters", name)
+		}
+		// escape the value, which can contain the separators, like the Forwarded header
+		headerskv = append(headerskv, name+mapKeySeparator+url.QueryEscape(value))
+	}
 	source := c.Source
 	if source == "" {
 		source = "trino-go-client"
@@ -217,13 +514,101 @@ func (c *Config) FormatDSN() (string, error) {
 		query.Add(forwardAuthorizationHeaderConfig, "true")
 	}
 
+	if c.ExplicitPrepare {
+		query.Add(explicitPrepareConfig, "true")
+	}
+
+	if c.MaxResponseBodySize < 0 {
+		return "", fmt.Errorf("trino: client configuration error, the maximum response body size cannot be negative")
+	}
+	if c.MaxResponseBodySize > 0 {
+		query.Add(maxResponseBodySizeConfig, strconv.FormatInt(c.MaxResponseBodySize, 10))
+	}
+	if c.ReadBufferSize < 0 {
+		return "", fmt.Errorf("trino: client configuration error, the read buffer size cannot be negative")
+	}
+	if c.ReadBufferSize > 0 {
+		query.Add(readBufferSizeConfig, strconv.Itoa(c.ReadBufferSize))
+	}
+	if c.ServerStartupRetries != 0 {
+		query.Add(serverStartupRetriesConfig, strconv.Itoa(c.ServerStartupRetries))
+	}
+
 	KerberosEnabled, _ := strconv.ParseBool(c.KerberosEnabled)
 	isSSL := serverURL.Scheme == "https"
 
+	if c.Schema != "" && c.Catalog == "" {
+		return "", fmt.Errorf("trino: client configuration error, a schema cannot be specified without a catalog")
+	}
+
+	hasClientCert := c.SSLClientCertPath != "" || c.SSLClientKeyPath != "" || c.SSLClientCert != "" || c.SSLClientKey != ""
 	if c.CustomClientName != "" {
 		if c.SSLCert != "" || c.SSLCertPath != "" {
 			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specific together with a custom SSL certificate")
 		}
+		if hasClientCert {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with a client certificate")
+		}
+		if c.CompressionDisabled {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with disabled compression")
+		}
+		if c.HTTP2 {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with HTTP/2")
+		}
+		if c.ConnectTimeout != 0 {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with a connect timeout")
+		}
+		if c.SocketTimeout != 0 {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with a socket timeout")
+		}
+		if c.KeepAlive != 0 || c.KeepAliveInterval != 0 {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with TCP keep-alive settings")
+		}
+		if c.HostVerification != nil {
+			return "", fmt.Errorf("trino: client configuration error, a custom client cannot be specified together with host verification")
+		}
+	}
+	if c.ConnectTimeout < 0 || c.QueryTimeout < 0 || c.SocketTimeout < 0 {
+		return "", fmt.Errorf("trino: client configuration error, timeouts cannot be negative")
+	}
+	if c.ConnectTimeout > 0 {
+		query.Add(connectTimeoutConfig, c.ConnectTimeout.String())
+	}
+	if c.QueryTimeout > 0 {
+		query.Add(queryTimeoutConfig, c.QueryTimeout.String())
+	}
+	if c.SocketTimeout > 0 {
+		if c.HTTP2 && !isSSL {
+			return "", fmt.Errorf("trino: client configuration error, a socket timeout cannot be specified together with unencrypted HTTP/2")
+		}
+		query.Add(socketTimeoutConfig, c.SocketTimeout.String())
+	}
+	if c.KeepAlive != 0 {
+		query.Add(keepAliveConfig, c.KeepAlive.String())
+	}
+	if c.KeepAliveInterval < 0 {
+		return "", fmt.Errorf("trino: client configuration error, the TCP keep-alive interval cannot be negative")
+	}
+	if c.KeepAliveInterval > 0 {
+		if c.KeepAlive < 0 {
+			return "", fmt.Errorf("trino: client configuration error, a TCP keep-alive interval cannot be specified together with disabled keep-alives")
+		}
+		query.Add(keepAliveIntervalConfig, c.KeepAliveInterval.String())
+	}
+	if c.CompressionDisabled {
+		query.Add(compressionDisabledConfig, "true")
+	}
+	if c.HTTP2 {
+		query.Add(http2Config, "true")
+	}
+	if c.TraceQueryText {
+		query.Add(traceQueryTextConfig, "true")
+	}
+	if c.EnableQueryInfo {
+		query.Add(enableQueryInfoConfig, "true")
+	}
+	if c.RowValues {
+		query.Add(rowValuesConfig, "true")
 	}
 	if c.SSLCertPath != "" {
 		if !isSSL {
@@ -245,6 +630,35 @@ func (c *Config) FormatDSN() (string, error) {
 		query.Add(sslCertConfig, c.SSLCert)
 	}
 
+	if hasClientCert {
+		if !isSSL {
+			return "", fmt.Errorf("trino: client configuration error, SSL must be enabled to specify a client certificate")
+		}
+		if (c.SSLClientCertPath != "" || c.SSLClientKeyPath != "") && (c.SSLClientCert != "" || c.SSLClientKey != "") {
+			return "", fmt.Errorf("trino: client configuration error, a client certificate and key files cannot be specified together with certificate and key strings")
+		}
+		if (c.SSLClientCertPath == "") != (c.SSLClientKeyPath == "") || (c.SSLClientCert == "") != (c.SSLClientKey == "") {
+			return "", fmt.Errorf("trino: client configuration error, a client certificate must be specified together with its private key")
+		}
+		for k, v := range map[string]string{
+			sslClientCertPathConfig: c.SSLClientCertPath,
+			sslClientKeyPathConfig:  c.SSLClientKeyPath,
+			sslClientCertConfig:     c.SSLClientCert,
+			sslClientKeyConfig:      c.SSLClientKey,
+		} {
+			if v != "" {
+				query.Add(k, v)
+			}
+		}
+	}
+
+	if c.HostVerification != nil {
+		if !isSSL {
+			return "", fmt.Errorf("trino: client configuration error, SSL must be enabled to configure host verification")
+		}
+		query.Add(hostVerificationConfig, strconv.FormatBool(*c.HostVerification))
+	}
+
 	if KerberosEnabled {
 		if !isSSL {
 			return "", fmt.Errorf("trino: client configuration error, SSL must be enabled for secure env")
@@ -259,34 +673,308 @@ func (c *Config) FormatDSN() (string, error) {
 			remoteServiceName = "trino"
 		}
 		query.Add(kerberosRemoteServiceNameConfig, remoteServiceName)
+		if c.KerberosServiceKDCHostname != "" {
+			query.Add(kerberosServiceKDCHostnameConfig, c.KerberosServiceKDCHostname)
+		}
+	}
+
+	if c.OAuth2TokenURL != "" || c.OAuth2ClientID != "" || c.OAuth2ClientSecret != "" || len(c.OAuth2Scopes) > 0 {
+		if !isSSL {
+			return "", fmt.Errorf("trino: client configuration error, SSL must be enabled for OAuth2 authentication")
+		}
+		if c.OAuth2TokenURL == "" || c.OAuth2ClientID == "" || c.OAuth2ClientSecret == "" {
+			return "", fmt.Errorf("trino: client configuration error, OAuth2 authentication requires a token URL, a client ID and a client secret")
+		}
+		if tokenURL, err := url.Parse(c.OAuth2TokenURL); err != nil || tokenURL.Scheme != "https" || tokenURL.Host == "" {
+			return "", fmt.Errorf("trino: client configuration error, the OAuth2 token URL must be an absolute HTTPS URL")
+		}
+		if c.AccessToken != "" || KerberosEnabled || c.ForwardAuthorizationHeader {
+			return "", fmt.Errorf("trino: client configuration error, OAuth2 authentication cannot be specified together with an access token, Kerberos or a forwarded authorization header")
+		}
+		for _, scope := range c.OAuth2Scopes {
+			if scope == "" || !isASCII(scope) {
+				return "", fmt.Errorf("trino: client configuration error, invalid OAuth2 scope %q", scope)
+			}
+		}
+		query.Add(oauth2TokenURLConfig, c.OAuth2TokenURL)
+		query.Add(oauth2ClientIDConfig, c.OAuth2ClientID)
+		query.Add(oauth2ClientSecretConfig, c.OAuth2ClientSecret)
+		if len(c.OAuth2Scopes) > 0 {
+			query.Add(oauth2ScopesConfig, strings.Join(c.OAuth2Scopes, " "))
+		}
+	}
+
+	if c.Locale != "" && !isLanguageTag(c.Locale) {
+		return "", fmt.Errorf("trino: client configuration error, invalid locale %q, expected a language tag like en-US", c.Locale)
+	}
+	if c.TimeZone != "" && !isASCII(c.TimeZone) {
+		return "", fmt.Errorf("trino: client configuration error, invalid time zone %q, expected a zone ID like America/New_York", c.TimeZone)
+	}
+	if c.QueryTraceToken != "" && !isASCII(c.QueryTraceToken) {
+		return "", fmt.Errorf("trino: client configuration error, the trace token contains spaces or is not printable ASCII")
 	}
 
 	// ensure consistent order of items
 	sort.Strings(sessionkv)
 	sort.Strings(credkv)
+	sort.Strings(roleskv)
+	sort.Strings(headerskv)
 
 	for k, v := range map[string]string{
 		"catalog":            c.Catalog,
 		"schema":             c.Schema,
 		"session_properties": strings.Join(sessionkv, mapEntrySeparator),
 		"extra_credentials":  strings.Join(credkv, mapEntrySeparator),
+		"roles":              strings.Join(roleskv, mapEntrySeparator),
+		"forwarded_headers":  strings.Join(headerskv, mapEntrySeparator),
 		"custom_client":      c.CustomClientName,
+		"application_name":   c.ApplicationName,
+		localeConfig:         c.Locale,
+		timeZoneConfig:       c.TimeZone,
+		traceTokenConfig:     c.QueryTraceToken,
 		accessTokenConfig:    c.AccessToken,
+		userAgentConfig:      c.UserAgent,
 	} {
 		if v != "" {
 			query[k] = []string{v}
 		}
 	}
+	for k, v := range c.AdditionalQueryParameters {
+		if k == "" {
+			return "", fmt.Errorf("trino: client configuration error, an additional query parameter name cannot be empty")
+		}
+		if !query.Has(k) {
+			query.Set(k, v)
+		}
+	}
 	serverURL.RawQuery = query.Encode()
 	return serverURL.String(), nil
 }
 
+// dsnParameters are the names of the DSN parameters modeled by the fields of Config.
+var dsnParameters = map[string]bool{
+	"source":                         true,
+	"application_name":               true,
+	"catalog":                        true,
+	"schema":                         true,
+	"session_properties":             true,
+	"extra_credentials":              true,
+	"roles":                          true,
+	"forwarded_headers":              true,
+	"custom_client":                  true,
+	kerberosEnabledConfig:            true,
+	kerberosKeytabPathConfig:         true,
+	kerberosPrincipalConfig:          true,
+	kerberosRealmConfig:              true,
+	kerberosConfigPathConfig:         true,
+	kerberosRemoteServiceNameConfig:  true,
+	kerberosServiceKDCHostnameConfig: true,
+	oauth2TokenURLConfig:             true,
+	oauth2ClientIDConfig:             true,
+	oauth2ClientSecretConfig:         true,
+	oauth2ScopesConfig:               true,
+	sslCertPathConfig:                true,
+	sslCertConfig:                    true,
+	sslClientCertPathConfig:          true,
+	sslClientKeyPathConfig:           true,
+	sslClientCertConfig:              true,
+	sslClientKeyConfig:               true,
+	hostVerificationConfig:           true,
+	accessTokenConfig:                true,
+	explicitPrepareConfig:            true,
+	forwardAuthorizationHeaderConfig: true,
+	maxResponseBodySizeConfig:        true,
+	readBufferSizeConfig:             true,
+	serverStartupRetriesConfig:       true,
+	compressionDisabledConfig:        true,
+	http2Config:                      true,
+	connectTimeoutConfig:             true,
+	queryTimeoutConfig:               true,
+	socketTimeoutConfig:              true,
+	keepAliveConfig:                  true,
+	keepAliveIntervalConfig:          true,
+	userAgentConfig:                  true,
+	traceQueryTextConfig:             true,
+	enableQueryInfoConfig:            true,
+	localeConfig:                     true,
+	timeZoneConfig:                   true,
+	traceTokenConfig:                 true,
+	rowValuesConfig:                  true,
+}
+
+// ParseDSN returns the configuration encoded in a DSN string, as created by
+// Config.FormatDSN. Unrecognized parameters are returned in AdditionalQueryParameters.
+func ParseDSN(dsn string) (*Config, error) {
+	serverURL, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("trino: malformed dsn: %w", err)
+	}
+	query := serverURL.Query()
+	serverURL.RawQuery = ""
+
+	c := &Config{
+		ServerURI:                  serverURL.String(),
+		Source:                     query.Get("source"),
+		ApplicationName:            query.Get("application_name"),
+		Locale:                     query.Get(localeConfig),
+		TimeZone:                   query.Get(timeZoneConfig),
+		QueryTraceToken:            query.Get(traceTokenConfig),
+		Catalog:                    query.Get("catalog"),
+		Schema:                     query.Get("schema"),
+		CustomClientName:           query.Get("custom_client"),
+		KerberosEnabled:            query.Get(kerberosEnabledConfig),
+		KerberosKeytabPath:         query.Get(kerberosKeytabPathConfig),
+		KerberosPrincipal:          query.Get(kerberosPrincipalConfig),
+		KerberosRemoteServiceName:  query.Get(kerberosRemoteServiceNameConfig),
+		KerberosRealm:              query.Get(kerberosRealmConfig),
+		KerberosConfigPath:         query.Get(kerberosConfigPathConfig),
+		KerberosServiceKDCHostname: query.Get(kerberosServiceKDCHostnameConfig),
+		SSLCertPath:                query.Get(sslCertPathConfig),
+		SSLCert:                    query.Get(sslCertConfig),
+		SSLClientCertPath:          query.Get(sslClientCertPathConfig),
+		SSLClientKeyPath:           query.Get(sslClientKeyPathConfig),
+		SSLClientCert:              query.Get(sslClientCertConfig),
+		SSLClientKey:               query.Get(sslClientKeyConfig),
+		AccessToken:                query.Get(accessTokenConfig),
+		OAuth2TokenURL:             query.Get(oauth2TokenURLConfig),
+		OAuth2ClientID:             query.Get(oauth2ClientIDConfig),
+		OAuth2ClientSecret:         query.Get(oauth2ClientSecretConfig),
+		UserAgent:                  query.Get(userAgentConfig),
+	}
+	c.ExplicitPrepare, _ = strconv.ParseBool(query.Get(explicitPrepareConfig))
+	c.ForwardAuthorizationHeader, _ = strconv.ParseBool(query.Get(forwardAuthorizationHeaderConfig))
+	c.CompressionDisabled, _ = strconv.ParseBool(query.Get(compressionDisabledConfig))
+	c.HTTP2, _ = strconv.ParseBool(query.Get(http2Config))
+	c.TraceQueryText, _ = strconv.ParseBool(query.Get(traceQueryTextConfig))
+	c.EnableQueryInfo, _ = strconv.ParseBool(query.Get(enableQueryInfoConfig))
+	c.RowValues, _ = strconv.ParseBool(query.Get(rowValuesConfig))
+	if v := query.Get(oauth2ScopesConfig); v != "" {
+		c.OAuth2Scopes = strings.Fields(v)
+	}
+	if v := query.Get(hostVerificationConfig); v != "" {
+		hostVerification, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", hostVerificationConfig, err)
+		}
+		c.HostVerification = &hostVerification
+	}
+	for name, d := range map[string]*time.Duration{
+		connectTimeoutConfig:    &c.ConnectTimeout,
+		queryTimeoutConfig:      &c.QueryTimeout,
+		socketTimeoutConfig:     &c.SocketTimeout,
+		keepAliveConfig:         &c.KeepAlive,
+		keepAliveIntervalConfig: &c.KeepAliveInterval,
+	} {
+		if v := query.Get(name); v != "" {
+			if *d, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("trino: invalid %s: %w", name, err)
+			}
+		}
+	}
+	if v := query.Get(maxResponseBodySizeConfig); v != "" {
+		c.MaxResponseBodySize, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", maxResponseBodySizeConfig, err)
+		}
+	}
+	if v := query.Get(readBufferSizeConfig); v != "" {
+		c.ReadBufferSize, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", readBufferSizeConfig, err)
+		}
+	}
+	if v := query.Get(serverStartupRetriesConfig); v != "" {
+		c.ServerStartupRetries, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", serverStartupRetriesConfig, err)
+		}
+	}
+	for name, m := range map[string]*map[string]string{
+		"session_properties": &c.SessionProperties,
+		"extra_credentials":  &c.ExtraCredentials,
+		"roles":              &c.Roles,
+	} {
+		if v := query.Get(name); v != "" {
+			if *m, err = parseDSNMap(name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v := query.Get("forwarded_headers"); v != "" {
+		if c.ForwardedHeaders, err = parseForwardedHeaders(v); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range query {
+		if !dsnParameters[k] {
+			if c.AdditionalQueryParameters == nil {
+				c.AdditionalQueryParameters = make(map[string]string)
+			}
+			c.AdditionalQueryParameters[k] = v[0]
+		}
+	}
+	return c, nil
+}
+
+// parseDSNMap parses a map encoded in a DSN parameter by Config.FormatDSN.
+func parseDSNMap(name, input string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, entry := range strings.Split(input, mapEntrySeparator) {
+		key, value, ok := strings.Cut(entry, mapKeySeparator)
+		if !ok {
+			return nil, fmt.Errorf("trino: Malformed %s: %s", name, input)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("trino: %s key is empty", name)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// parseForwardedHeaders parses the forwarded_headers DSN parameter, with values escaped by Config.FormatDSN.
+func parseForwardedHeaders(input string) (map[string]string, error) {
+	headers, err := parseDSNMap("forwarded_headers", input)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		if headers[name], err = url.QueryUnescape(value); err != nil {
+			return nil, fmt.Errorf("trino: Malformed forwarded_headers value of %s: %w", name, err)
+		}
+	}
+	return headers, nil
+}
+
+// defaultUserAgent identifies the driver and its version in the User-Agent header.
+var defaultUserAgent = "trino-go-client/" + driverVersion()
+
+// driverVersion returns the version of the driver module from the build information of the binary.
+func driverVersion() string {
+	const modulePath = "github.com/trinodb/trino-go-client"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
 // Conn is a Trino connection.
 type Conn struct {
 	baseURL                    string
 	auth                       *url.Userinfo
 	httpClient                 http.Client
 	httpHeaders                http.Header
+	ownTransport               interface{ CloseIdleConnections() }
+	oauth2Transport            *oauth2.Transport
 	kerberosEnabled            bool
 	kerberosClient             *client.Client
 	kerberosRemoteServiceName  string
@@ -294,11 +982,25 @@ type Conn struct {
 	progressUpdaterPeriod      queryProgressCallbackPeriod
 	useExplicitPrepare         bool
 	forwardAuthorizationHeader bool
+	maxResponseBodySize        int64
+	readBufferSize             int
+	serverStartupRetries       int
+	queryTimeout               time.Duration
+	traceQueryText             bool
+	enableQueryInfo            bool
+	rowValues                  bool
+	sessionCache               map[string]string
+	logger                     *slog.Logger
+	metrics                    ConnMetricsCallback
 }
 
 var (
 	_ driver.Conn               = &Conn{}
 	_ driver.ConnPrepareContext = &Conn{}
+	_ driver.ExecerContext      = &Conn{}
+	_ driver.NamedValueChecker  = &Conn{}
+	_ driver.Pinger             = &Conn{}
+	_ driver.SessionResetter    = &Conn{}
 )
 
 func newConn(dsn string) (*Conn, error) {
@@ -313,9 +1015,44 @@ func newConn(dsn string) (*Conn, error) {
 
 	forwardAuthorizationHeader, _ := strconv.ParseBool(query.Get(forwardAuthorizationHeaderConfig))
 
-	useExplicitPrepare := true
-	if query.Get(explicitPrepareConfig) != "" {
-		useExplicitPrepare, _ = strconv.ParseBool(query.Get(explicitPrepareConfig))
+	useExplicitPrepare, _ := strconv.ParseBool(query.Get(explicitPrepareConfig))
+
+	traceQueryText, _ := strconv.ParseBool(query.Get(traceQueryTextConfig))
+
+	enableQueryInfo, _ := strconv.ParseBool(query.Get(enableQueryInfoConfig))
+
+	rowValues, _ := strconv.ParseBool(query.Get(rowValuesConfig))
+
+	var maxResponseBodySize int64
+	if v := query.Get(maxResponseBodySizeConfig); v != "" {
+		maxResponseBodySize, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", maxResponseBodySizeConfig, err)
+		}
+	}
+
+	var readBufferSize int
+	if v := query.Get(readBufferSizeConfig); v != "" {
+		readBufferSize, err = strconv.Atoi(v)
+		if err != nil || readBufferSize < 0 {
+			return nil, fmt.Errorf("trino: invalid %s: %q", readBufferSizeConfig, v)
+		}
+	}
+
+	serverStartupRetries := defaultServerStartupRetries
+	if v := query.Get(serverStartupRetriesConfig); v != "" {
+		serverStartupRetries, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", serverStartupRetriesConfig, err)
+		}
+	}
+
+	var queryTimeout time.Duration
+	if v := query.Get(queryTimeoutConfig); v != "" {
+		queryTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("trino: invalid %s: %w", queryTimeoutConfig, err)
+		}
 	}
 
 	var kerberosClient *client.Client
@@ -329,6 +1066,9 @@ func newConn(dsn string) (*Conn, error) {
 		if err != nil {
 			return nil, fmt.Errorf("trino: Error loading krb config: %w", err)
 		}
+		if kdc := query.Get(kerberosServiceKDCHostnameConfig); kdc != "" {
+			overrideKDC(conf, query.Get(kerberosRealmConfig), kdc)
+		}
 
 		kerberosClient = client.NewWithKeytab(query.Get(kerberosPrincipalConfig), query.Get(kerberosRealmConfig), kt, conf)
 		loginErr := kerberosClient.Login()
@@ -338,33 +1078,20 @@ func newConn(dsn string) (*Conn, error) {
 	}
 
 	var httpClient = http.DefaultClient
+	var ownTransport interface{ CloseIdleConnections() }
 	if clientKey := query.Get("custom_client"); clientKey != "" {
 		httpClient = getCustomClient(clientKey)
 		if httpClient == nil {
 			return nil, fmt.Errorf("trino: custom client not registered: %q", clientKey)
 		}
-	} else if serverURL.Scheme == "https" {
-
-		cert := []byte(query.Get(sslCertConfig))
-
-		if certPath := query.Get(sslCertPathConfig); certPath != "" {
-			cert, err = os.ReadFile(certPath)
-			if err != nil {
-				return nil, fmt.Errorf("trino: Error loading SSL Cert File: %w", err)
-			}
+	} else {
+		httpClient, err = newHTTPClient(serverURL, query)
+		if err != nil {
+			return nil, err
 		}
-
-		if len(cert) != 0 {
-			certPool := x509.NewCertPool()
-			certPool.AppendCertsFromPEM(cert)
-
-			httpClient = &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						RootCAs: certPool,
-					},
-				},
-			}
+		// a transport created for the connection isn't shared, so its connections are closed with it
+		if httpClient != http.DefaultClient {
+			ownTransport, _ = httpClient.Transport.(interface{ CloseIdleConnections() })
 		}
 	}
 
@@ -372,11 +1099,30 @@ func newConn(dsn string) (*Conn, error) {
 		baseURL:                    serverURL.Scheme + "://" + serverURL.Host,
 		httpClient:                 *httpClient,
 		httpHeaders:                make(http.Header),
+		ownTransport:               ownTransport,
 		kerberosClient:             kerberosClient,
 		kerberosEnabled:            kerberosEnabled,
 		kerberosRemoteServiceName:  query.Get(kerberosRemoteServiceNameConfig),
 		useExplicitPrepare:         useExplicitPrepare,
 		forwardAuthorizationHeader: forwardAuthorizationHeader,
+		maxResponseBodySize:        maxResponseBodySize,
+		readBufferSize:             readBufferSize,
+		serverStartupRetries:       serverStartupRetries,
+		queryTimeout:               queryTimeout,
+		traceQueryText:             traceQueryText,
+		enableQueryInfo:            enableQueryInfo,
+		rowValues:                  rowValues,
+		sessionCache:               make(map[string]string),
+	}
+
+	if tokenURL := query.Get(oauth2TokenURLConfig); tokenURL != "" {
+		c.oauth2Transport = newOAuth2Transport(c.httpClient, clientcredentials.Config{
+			ClientID:     query.Get(oauth2ClientIDConfig),
+			ClientSecret: query.Get(oauth2ClientSecretConfig),
+			TokenURL:     tokenURL,
+			Scopes:       strings.Fields(query.Get(oauth2ScopesConfig)),
+		})
+		c.httpClient.Transport = c.oauth2Transport
 	}
 
 	var user string
@@ -389,19 +1135,38 @@ func newConn(dsn string) (*Conn, error) {
 	}
 
 	for k, v := range map[string]string{
-		trinoUserHeader:     user,
-		trinoSourceHeader:   query.Get("source"),
-		trinoCatalogHeader:  query.Get("catalog"),
-		trinoSchemaHeader:   query.Get("schema"),
-		authorizationHeader: getAuthorization(query.Get(accessTokenConfig)),
+		trinoUserHeader:       user,
+		trinoSourceHeader:     query.Get("source"),
+		trinoClientInfoHeader: query.Get("application_name"),
+		trinoLanguageHeader:   query.Get(localeConfig),
+		trinoTimeZoneHeader:   query.Get(timeZoneConfig),
+		trinoTraceTokenHeader: query.Get(traceTokenConfig),
+		trinoCatalogHeader:    query.Get("catalog"),
+		trinoSchemaHeader:     query.Get("schema"),
+		authorizationHeader:   getAuthorization(query.Get(accessTokenConfig)),
 	} {
 		if v != "" {
 			c.httpHeaders.Add(k, v)
 		}
 	}
+	userAgent := query.Get(userAgentConfig)
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	c.httpHeaders.Set(userAgentHeader, userAgent)
+	if v := query.Get("forwarded_headers"); v != "" {
+		headers, err := parseForwardedHeaders(v)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range headers {
+			c.httpHeaders.Set(name, value)
+		}
+	}
 	for header, param := range map[string]string{
 		trinoSessionHeader:         "session_properties",
 		trinoExtraCredentialHeader: "extra_credentials",
+		trinoRoleHeader:            "roles",
 	} {
 		v := query.Get(param)
 		if v != "" {
@@ -415,6 +1180,46 @@ func newConn(dsn string) (*Conn, error) {
 	return c, nil
 }
 
+// validateForwardedHeader checks that a forwarded header doesn't replace one set by the driver.
+func validateForwardedHeader(name string) error {
+	name = http.CanonicalHeaderKey(name)
+	if name == "" || strings.ContainsAny(name, mapKeySeparator+mapEntrySeparator) {
+		return fmt.Errorf("trino: client configuration error, invalid forwarded header name %q", name)
+	}
+	if strings.HasPrefix(name, trinoHeaderPrefix) || name == authorizationHeader {
+		return fmt.Errorf("trino: client configuration error, the %s header cannot be forwarded, use the dedicated configuration instead", name)
+	}
+	return nil
+}
+
+// isHeaderValue reports whether s is a non-empty HTTP header value without control characters.
+func isHeaderValue(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRole checks that a role is either ROLE{name}, ALL or NONE.
+func validateRole(catalog, role string) error {
+	if catalog == "" {
+		return fmt.Errorf("trino: client configuration error, role catalog is empty")
+	}
+	switch role {
+	case "ALL", "NONE":
+		return nil
+	}
+	if strings.HasPrefix(role, "ROLE{") && strings.HasSuffix(role, "}") && len(role) > len("ROLE{}") {
+		return nil
+	}
+	return fmt.Errorf("trino: client configuration error, role for catalog '%s' must be ROLE{name}, ALL or NONE, got '%s'", catalog, role)
+}
+
 func decodeMapHeader(name, input string) ([]string, error) {
 	result := []string{}
 	for _, entry := range strings.Split(input, mapEntrySeparator) {
@@ -424,25 +1229,50 @@ func decodeMapHeader(name, input string) ([]string, error) {
 		}
 		key := parts[0]
 		value := parts[1]
-		if len(key) == 0 {
-			return nil, fmt.Errorf("trino: %s key is empty", name)
-		}
-		if len(value) == 0 {
-			return nil, fmt.Errorf("trino: %s value is empty", name)
-		}
-		if !isASCII(key) {
-			return nil, fmt.Errorf("trino: %s key '%s' contains spaces or is not printable ASCII", name, key)
-		}
-		if !isASCII(value) {
-			// do not log value as it may contain sensitive information
-			return nil, fmt.Errorf("trino: %s value for key '%s' contains spaces or is not printable ASCII", name, key)
+		if err := validateMapHeaderEntry(name, key, value); err != nil {
+			return nil, err
 		}
 		result = append(result, key+"="+url.QueryEscape(value))
 	}
 	return result, nil
 }
 
-func isASCII(s string) bool {
+// validateMapHeaderEntry checks that an entry of the name DSN parameter can be sent in a header.
+func validateMapHeaderEntry(name, key, value string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("trino: %s key is empty", name)
+	}
+	if len(value) == 0 {
+		return fmt.Errorf("trino: %s value is empty", name)
+	}
+	if !isASCII(key) {
+		return fmt.Errorf("trino: %s key '%s' contains spaces or is not printable ASCII", name, key)
+	}
+	if !isASCII(value) {
+		// do not log value as it may contain sensitive information
+		return fmt.Errorf("trino: %s value for key '%s' contains spaces or is not printable ASCII", name, key)
+	}
+	return nil
+}
+
+// isLanguageTag reports whether s has the syntax of an IETF language tag, subtags of ASCII letters
+// and digits separated by hyphens.
+func isLanguageTag(s string) bool {
+	for _, subtag := range strings.Split(s, "-") {
+		if len(subtag) == 0 || len(subtag) > 8 {
+			return false
+		}
+		for i := 0; i < len(subtag); i++ {
+			c := subtag[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isASCII(s string) bool {
 	for i := 0; i < len(s); i++ {
 		if s[i] < '\u0021' || s[i] > '\u007E' {
 			return false
@@ -451,68 +1281,1002 @@ func isASCII(s string) bool {
 	return true
 }
 
-func getAuthorization(token string) string {
-	if token == "" {
-		return ""
-	}
-	return fmt.Sprintf("Bearer %s", token)
+func getAuthorization(token string) string {
+	if token == "" {
+		return ""
+	}
+	return fmt.Sprintf("Bearer %s", token)
+}
+
+// overrideKDC makes Kerberos clients using conf contact kdc for realm,
+// instead of the KDCs configured for it, if any.
+func overrideKDC(conf *config.Config, realm, kdc string) {
+	if realm == "" {
+		realm = conf.LibDefaults.DefaultRealm
+	}
+	if _, _, err := net.SplitHostPort(kdc); err != nil {
+		kdc = net.JoinHostPort(kdc, "88")
+	}
+	for i := range conf.Realms {
+		if conf.Realms[i].Realm == realm {
+			conf.Realms[i].KDC = []string{kdc}
+			return
+		}
+	}
+	conf.Realms = append(conf.Realms, config.Realm{Realm: realm, KDC: []string{kdc}})
+}
+
+// newHTTPClient returns the HTTP client used when no custom client is registered.
+func newHTTPClient(serverURL *url.URL, query url.Values) (*http.Client, error) {
+	compressionDisabled, _ := strconv.ParseBool(query.Get(compressionDisabledConfig))
+	useHTTP2, _ := strconv.ParseBool(query.Get(http2Config))
+	var connectTimeout, socketTimeout, keepAlive, keepAliveInterval time.Duration
+	for name, d := range map[string]*time.Duration{
+		connectTimeoutConfig:    &connectTimeout,
+		socketTimeoutConfig:     &socketTimeout,
+		keepAliveConfig:         &keepAlive,
+		keepAliveIntervalConfig: &keepAliveInterval,
+	} {
+		if v := query.Get(name); v != "" {
+			var err error
+			if *d, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("trino: invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	var transport *http.Transport
+	if serverURL.Scheme == "https" {
+
+		cert := []byte(query.Get(sslCertConfig))
+
+		if certPath := query.Get(sslCertPathConfig); certPath != "" {
+			var err error
+			cert, err = os.ReadFile(certPath)
+			if err != nil {
+				return nil, fmt.Errorf("trino: Error loading SSL Cert File: %w", err)
+			}
+		}
+
+		var tlsConfig *tls.Config
+		if len(cert) != 0 {
+			certPool := x509.NewCertPool()
+			certPool.AppendCertsFromPEM(cert)
+
+			tlsConfig = &tls.Config{
+				RootCAs: certPool,
+			}
+		}
+
+		clientCert, err := loadClientCertificate(query)
+		if err != nil {
+			return nil, err
+		}
+		if clientCert != nil {
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			tlsConfig.Certificates = []tls.Certificate{*clientCert}
+		}
+
+		if v := query.Get(hostVerificationConfig); v != "" {
+			hostVerification, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("trino: invalid %s: %w", hostVerificationConfig, err)
+			}
+			if !hostVerification {
+				if tlsConfig == nil {
+					tlsConfig = &tls.Config{}
+				}
+				tlsConfig.InsecureSkipVerify = true
+			}
+		}
+
+		if tlsConfig != nil {
+			transport = &http.Transport{
+				TLSClientConfig: tlsConfig,
+				// like the default transport, negotiate HTTP/2 with servers that support it
+				ForceAttemptHTTP2: true,
+			}
+		}
+	}
+
+	customDialer := connectTimeout > 0 || keepAlive != 0 || keepAliveInterval > 0
+	if transport == nil && (compressionDisabled || useHTTP2 || customDialer || socketTimeout > 0) {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	if compressionDisabled {
+		transport.DisableCompression = true
+	}
+
+	if customDialer {
+		dialer := &net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		if keepAlive != 0 {
+			dialer.KeepAlive = keepAlive
+		}
+		if keepAliveInterval > 0 {
+			setKeepAliveInterval(dialer, keepAliveInterval)
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if connectTimeout > 0 {
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+
+	if socketTimeout > 0 {
+		transport.ResponseHeaderTimeout = socketTimeout
+	}
+
+	if useHTTP2 {
+		if socketTimeout > 0 && serverURL.Scheme != "https" {
+			return nil, fmt.Errorf("trino: %s cannot be combined with %s over unencrypted connections", socketTimeoutConfig, http2Config)
+		}
+		return newHTTP2Client(serverURL, transport)
+	}
+
+	if transport == nil {
+		return http.DefaultClient, nil
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// loadClientCertificate loads the client certificate for mutual TLS authentication, if any.
+func loadClientCertificate(query url.Values) (*tls.Certificate, error) {
+	if certPath := query.Get(sslClientCertPathConfig); certPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, query.Get(sslClientKeyPathConfig))
+		if err != nil {
+			return nil, fmt.Errorf("trino: Error loading SSL client certificate files: %w", err)
+		}
+		return &cert, nil
+	}
+	if certPEM := query.Get(sslClientCertConfig); certPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(query.Get(sslClientKeyConfig)))
+		if err != nil {
+			return nil, fmt.Errorf("trino: Error loading SSL client certificate: %w", err)
+		}
+		return &cert, nil
+	}
+	return nil, nil
+}
+
+const (
+	// close HTTP/2 connections if no frame is received for this long after a ping
+	http2ReadIdleTimeout = 30 * time.Second
+	http2PingTimeout     = 15 * time.Second
+)
+
+// newHTTP2Client returns an HTTP client that uses HTTP/2 for all requests. Over TLS, the protocol
+// is negotiated with ALPN, as it is without the http2 parameter, and only the health-check pings
+// are added. Unencrypted connections use HTTP/2 with prior knowledge (h2c), with a bare HTTP/2
+// transport that only takes the dialer and compression setting of the HTTP/1.1 transport: it has
+// no response header timeout, and there are no TLS settings to carry over.
+func newHTTP2Client(serverURL *url.URL, transport *http.Transport) (*http.Client, error) {
+	if serverURL.Scheme != "https" {
+		dial := transport.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		return &http.Client{Transport: &http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: transport.DisableCompression,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+			ReadIdleTimeout: http2ReadIdleTimeout,
+			PingTimeout:     http2PingTimeout,
+		}}, nil
+	}
+
+	// the default transport may already have HTTP/2 configured, which cannot be configured twice
+	transport.TLSNextProto = nil
+	h2Transport, err := http2.ConfigureTransports(transport)
+	if err != nil {
+		return nil, fmt.Errorf("trino: Error configuring HTTP/2: %w", err)
+	}
+	h2Transport.ReadIdleTimeout = http2ReadIdleTimeout
+	h2Transport.PingTimeout = http2PingTimeout
+	return &http.Client{Transport: transport}, nil
+}
+
+// registry for custom http clients
+var customClientRegistry = struct {
+	sync.RWMutex
+	Index map[string]http.Client
+}{
+	Index: make(map[string]http.Client),
+}
+
+// RegisterCustomClient associates a client to a key in the driver's registry.
+//
+// Register your custom client in the driver, then refer to it by name in the DSN, on the call to sql.Open:
+//
+//	foobarClient := &http.Client{
+//		Transport: &http.Transport{
+//			Proxy: http.ProxyFromEnvironment,
+//			DialContext: (&net.Dialer{
+//				Timeout:   30 * time.Second,
+//				KeepAlive: 30 * time.Second,
+//				DualStack: true,
+//			}).DialContext,
+//			MaxIdleConns:          100,
+//			IdleConnTimeout:       90 * time.Second,
+//			TLSHandshakeTimeout:   10 * time.Second,
+//			ExpectContinueTimeout: 1 * time.Second,
+//			TLSClientConfig:       &tls.Config{
+//			// your config here...
+//			},
+//		},
+//	}
+//	trino.RegisterCustomClient("foobar", foobarClient)
+//	db, err := sql.Open("trino", "https://user@localhost:8080?custom_client=foobar")
+func RegisterCustomClient(key string, client *http.Client) error {
+	if _, err := strconv.ParseBool(key); err == nil {
+		return fmt.Errorf("trino: custom client key %q is reserved", key)
+	}
+	customClientRegistry.Lock()
+	customClientRegistry.Index[key] = *client
+	customClientRegistry.Unlock()
+	return nil
+}
+
+// DeregisterCustomClient removes the client associated to the key.
+func DeregisterCustomClient(key string) {
+	customClientRegistry.Lock()
+	delete(customClientRegistry.Index, key)
+	customClientRegistry.Unlock()
+}
+
+func getCustomClient(key string) *http.Client {
+	customClientRegistry.RLock()
+	defer customClientRegistry.RUnlock()
+	if client, ok := customClientRegistry.Index[key]; ok {
+		return &client
+	}
+	return nil
+}
+
+// newOAuth2Transport returns a transport wrapping the transport of client, which sets the Authorization
+// header of every request to a bearer token obtained with the client credentials grant of config.
+// Tokens are requested with client, and transparently requested again when they expire.
+//
+// The token source is replaced by the one of the connector, if any, so that it lives as long as the
+// sql.DB and is shared by its connections, which all use the same credentials.
+func newOAuth2Transport(client http.Client, config clientcredentials.Config) *oauth2.Transport {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &client)
+	return &oauth2.Transport{Source: config.TokenSource(ctx), Base: base}
+}
+
+// DebugBodyLimit is the maximum number of bytes of each response body written by debug transports,
+// 0 to only write the status and headers of responses.
+var DebugBodyLimit = 0
+
+// redactedDebugHeaders are the headers whose values debug transports don't write, as they contain credentials.
+var redactedDebugHeaders = []string{authorizationHeader, "Proxy-Authorization", trinoExtraCredentialHeader}
+
+type debugTransport struct {
+	inner http.RoundTripper
+	mu    sync.Mutex
+	w     io.Writer
+}
+
+// NewDebugTransport returns a transport that writes the method, URL and headers of every request
+// sent with inner to w, followed by the status and headers of its response and the first
+// DebugBodyLimit bytes of the response body. If inner is nil, http.DefaultTransport is used.
+//
+// Credentials in the Authorization and X-Trino-Extra-Credential headers are redacted. It can be used
+// in the Middleware field of Config, or as the transport of a client registered with RegisterCustomClient:
+//
+//	client := &http.Client{Transport: trino.NewDebugTransport(nil, os.Stderr)}
+//	trino.RegisterCustomClient("debug", client)
+func NewDebugTransport(inner http.RoundTripper, w io.Writer) http.RoundTripper {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &debugTransport{inner: inner, w: w}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "> %s %s\n", req.Method, req.URL)
+	writeDebugHeaders(&buf, "> ", req.Header)
+	t.write(buf.Bytes())
+
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(req)
+	buf.Reset()
+	if err != nil {
+		fmt.Fprintf(&buf, "< %s %s failed after %v: %v\n", req.Method, req.URL, time.Since(start), err)
+		t.write(buf.Bytes())
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "< %s %s %s in %v\n", resp.Proto, resp.Status, req.URL, time.Since(start))
+	writeDebugHeaders(&buf, "< ", resp.Header)
+	if limit := DebugBodyLimit; limit > 0 && resp.Body != nil {
+		prefix, readErr := io.ReadAll(io.LimitReader(resp.Body, int64(limit)))
+		rest := io.Reader(resp.Body)
+		if readErr != nil {
+			rest = errReader{readErr}
+		}
+		// keep the whole body readable by the caller
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), rest), resp.Body}
+		buf.WriteString("<\n")
+		buf.Write(prefix)
+		buf.WriteString("\n")
+	}
+	t.write(buf.Bytes())
+	return resp, nil
+}
+
+func (t *debugTransport) write(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.Write(p)
+}
+
+// writeDebugHeaders writes headers sorted by name, one per line, with credentials redacted.
+func writeDebugHeaders(buf *bytes.Buffer, prefix string, headers http.Header) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			for _, redacted := range redactedDebugHeaders {
+				if http.CanonicalHeaderKey(name) == redacted {
+					value = "<redacted>"
+				}
+			}
+			fmt.Fprintf(buf, "%s%s: %s\n", prefix, name, value)
+		}
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+type transactionIDKey struct{}
+
+// WithTransactionID returns a copy of ctx that makes queries run in the Trino transaction identified by txID.
+//
+// A transaction started with a START TRANSACTION query is used by the following queries on the same
+// connection until it is committed or rolled back, or the connection is returned to the pool. Get its
+// ID with Conn.TransactionID and pass it with WithTransactionID to run queries in the transaction
+// regardless of the connection used.
+func WithTransactionID(ctx context.Context, txID string) context.Context {
+	return context.WithValue(ctx, transactionIDKey{}, txID)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx that sends id in the X-Trino-Client-Request-ID header
+// of the request submitting a query, for example to correlate it with client logs or to
+// let a proxy recognize a retried submission.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+type traceTokenKey struct{}
+
+// WithTraceToken returns a copy of ctx that sends token in the X-Trino-Trace-Token header of
+// the request submitting a query, instead of the QueryTraceToken of the configuration. Trino
+// propagates the token through the query execution and includes it in its logs.
+func WithTraceToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, traceTokenKey{}, token)
+}
+
+type queryTagsKey struct{}
+
+// WithQueryTag returns a copy of ctx that adds tag to the client tags of the queries run with it.
+func WithQueryTag(ctx context.Context, tag string) context.Context {
+	return WithQueryTags(ctx, tag)
+}
+
+// WithQueryTags returns a copy of ctx that adds tags to the client tags of the queries run with it.
+//
+// Client tags are sent in the X-Trino-Client-Tags header, and can be used to categorize queries,
+// for example in resource group selectors. Tags cannot contain commas.
+func WithQueryTags(ctx context.Context, tags ...string) context.Context {
+	existing, _ := ctx.Value(queryTagsKey{}).([]string)
+	return context.WithValue(ctx, queryTagsKey{}, append(existing[:len(existing):len(existing)], tags...))
+}
+
+type queryOptionsKey struct{}
+
+// trinoQueryOptions holds the per-query options attached to a context by WrapContext.
+type trinoQueryOptions struct {
+	user                  string
+	progressUpdater       ProgressUpdater
+	progressUpdaterPeriod time.Duration
+}
+
+// QueryOption configures the queries run with a context returned by WrapContext.
+type QueryOption func(*trinoQueryOptions)
+
+// WithUser returns a QueryOption that runs queries as user, like passing an X-Trino-User NamedArg.
+func WithUser(user string) QueryOption {
+	return func(o *trinoQueryOptions) {
+		o.user = user
+	}
+}
+
+// WithProgressCallback returns a QueryOption that calls updater with the progress of queries,
+// at most once per period unless the query state changes, like passing the X-Trino-Progress-Callback
+// and X-Trino-Progress-Callback-Period NamedArgs.
+func WithProgressCallback(updater ProgressUpdater, period time.Duration) QueryOption {
+	return func(o *trinoQueryOptions) {
+		o.progressUpdater = updater
+		o.progressUpdaterPeriod = period
+	}
+}
+
+// WrapContext returns a copy of ctx with opts applied to the queries run with it, on top of
+// the options attached by previous calls.
+//
+// It is an alternative to passing the driver-specific NamedArgs along with the query arguments.
+// NamedArgs take precedence over options set with WrapContext.
+func WrapContext(ctx context.Context, opts ...QueryOption) context.Context {
+	var o trinoQueryOptions
+	if existing, ok := ctx.Value(queryOptionsKey{}).(trinoQueryOptions); ok {
+		o = existing
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return context.WithValue(ctx, queryOptionsKey{}, o)
+}
+
+type sessionPropertiesKey struct{}
+
+// WithSessionProperties returns a copy of ctx that sets props as session properties of the queries
+// run with it, on top of the properties attached by previous calls.
+//
+// The properties are sent in the X-Trino-Session header of the request submitting each query only,
+// without changing the session of the connection. They take precedence over the session properties
+// of the connection with the same names.
+func WithSessionProperties(ctx context.Context, props map[string]string) context.Context {
+	existing, _ := ctx.Value(sessionPropertiesKey{}).(map[string]string)
+	merged := make(map[string]string, len(existing)+len(props))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range props {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, sessionPropertiesKey{}, merged)
+}
+
+// sessionHeaderValues returns the values of the X-Trino-Session header of the connection, with the
+// properties in props added or replacing the ones with the same names.
+func (c *Conn) sessionHeaderValues(props map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(props))
+	for k, v := range props {
+		if err := validateMapHeaderEntry("session_properties", k, v); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var values []string
+	for _, v := range c.httpHeaders.Values(trinoSessionHeader) {
+		name, _, _ := strings.Cut(v, "=")
+		if _, ok := props[name]; !ok {
+			values = append(values, v)
+		}
+	}
+	for _, k := range keys {
+		values = append(values, k+"="+url.QueryEscape(props[k]))
+	}
+	return values, nil
+}
+
+type queryIDKey struct{}
+
+// QueryIDContext executes a query that returns rows, like db.QueryContext, and also returns
+// the ID assigned to the query by Trino, for example to log it for debugging.
+//
+// The query ID is returned even if the query fails, as long as Trino accepted it.
+func QueryIDContext(ctx context.Context, db *sql.DB, query string, args ...interface{}) (string, *sql.Rows, error) {
+	var queryID string
+	rows, err := db.QueryContext(context.WithValue(ctx, queryIDKey{}, &queryID), query, args...)
+	return queryID, rows, err
+}
+
+// CancelQuery cancels the query with the given ID, for example one returned by QueryIDContext and running
+// in another goroutine or process, using the server and credentials of a connection from db.
+//
+// Cancelling a query that already finished is not an error.
+func CancelQuery(ctx context.Context, db *sql.DB, queryID string) error {
+	if queryID == "" {
+		return fmt.Errorf("trino: query ID cannot be empty")
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, err := trinoConn(driverConn)
+		if err != nil {
+			return err
+		}
+		req, err := c.newRequest(ctx, "DELETE", c.baseURL+"/v1/query/"+url.PathEscape(queryID), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return &ErrQueryFailed{Reason: err}
+		}
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+			return newErrQueryFailedFromResponse(resp)
+		}
+		return drainAndClose(resp)
+	})
+}
+
+// trinoConn returns the Trino connection of a driver connection from a sql.DB, or an error
+// if the sql.DB uses a different driver.
+func trinoConn(driverConn interface{}) (*Conn, error) {
+	c, ok := driverConn.(*Conn)
+	if !ok {
+		return nil, fmt.Errorf("trino: %T is not a Trino connection", driverConn)
+	}
+	return c, nil
+}
+
+type queryInfoURIKey struct{}
+
+// QueryInfoURIContext executes a query that returns rows, like db.QueryContext, and also returns
+// the URI of the JSON document describing the query, with its stages, splits and statistics, as
+// reported by Trino. The URI is only returned for queries run on connections with EnableQueryInfo set.
+func QueryInfoURIContext(ctx context.Context, db *sql.DB, query string, args ...interface{}) (string, *sql.Rows, error) {
+	var uri string
+	rows, err := db.QueryContext(context.WithValue(ctx, queryInfoURIKey{}, &uri), query, args...)
+	return uri, rows, err
+}
+
+// HealthStatus is the health of a Trino cluster, as returned by HealthCheck.
+type HealthStatus struct {
+	Healthy         bool          // Whether the coordinator finished starting up and ran a query
+	ServerVersion   string        // Version of the coordinator
+	StartupComplete bool          // Whether the coordinator finished starting up
+	Uptime          time.Duration // Time since the coordinator started
+}
+
+// serverInfo is the response of the /v1/info endpoint.
+type serverInfo struct {
+	NodeVersion struct {
+		Version string `json:"version"`
+	} `json:"nodeVersion"`
+	Starting bool   `json:"starting"`
+	Uptime   string `json:"uptime"`
+}
+
+// HealthCheck returns the health of the cluster of db, for example to probe it before routing traffic to it.
+// It reads the version, uptime and startup state of the coordinator from its /v1/info endpoint and, once
+// it finished starting up, also runs SELECT 1.
+//
+// The status is returned along with the error of the query, if it fails, while an error reading the
+// coordinator info is returned with an empty status.
+func HealthCheck(ctx context.Context, db *sql.DB) (HealthStatus, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return HealthStatus{}, err
+	}
+	defer conn.Close()
+
+	var status HealthStatus
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, err := trinoConn(driverConn)
+		if err != nil {
+			return err
+		}
+		req, err := c.newRequest(ctx, "GET", c.baseURL+"/v1/info", nil, nil)
+		if err != nil {
+			return err
+		}
+		// don't retry while the server is unavailable, like roundTrip does
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return &ErrQueryFailed{Reason: err}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return newErrQueryFailedFromResponse(resp)
+		}
+		var info serverInfo
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		drainAndClose(resp)
+		if err != nil {
+			return fmt.Errorf("trino: cannot decode server info: %w", err)
+		}
+		uptime, err := parseServerDuration(info.Uptime)
+		if err != nil {
+			return fmt.Errorf("trino: invalid server uptime %q: %w", info.Uptime, err)
+		}
+		status = HealthStatus{
+			ServerVersion:   info.NodeVersion.Version,
+			StartupComplete: !info.Starting,
+			Uptime:          uptime,
+		}
+		return nil
+	})
+	if err != nil || !status.StartupComplete {
+		return status, err
+	}
+	if err := conn.PingContext(ctx); err != nil {
+		return status, err
+	}
+	status.Healthy = true
+	return status, nil
+}
+
+// parseServerDuration parses a duration formatted by Trino, like 1.50h or 2.00d.
+func parseServerDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		d, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(d * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ExplainQuery returns the plan Trino would use to run query, as printed by EXPLAIN.
+//
+// EXPLAIN doesn't support parameters, so the arguments are serialized and replace the ? placeholders
+// of the query, outside of quoted strings, identifiers and comments. Named arguments are not supported.
+func ExplainQuery(ctx context.Context, db *sql.DB, query string, args ...interface{}) (string, error) {
+	return explain(ctx, db, "EXPLAIN ", query, args)
+}
+
+// ExplainAnalyze runs query with EXPLAIN ANALYZE, and returns its distributed plan annotated with
+// the execution statistics of each stage and operator, like CPU time and the number of input rows.
+// The arguments are handled like in ExplainQuery.
+//
+// The query is actually executed, so statements that modify data should not be explained this way.
+func ExplainAnalyze(ctx context.Context, db *sql.DB, query string, args ...interface{}) (string, error) {
+	return explain(ctx, db, "EXPLAIN ANALYZE ", query, args)
+}
+
+func explain(ctx context.Context, db *sql.DB, prefix, query string, args []interface{}) (string, error) {
+	query, err := interpolateArgs(query, args)
+	if err != nil {
+		return "", err
+	}
+	rows, err := db.QueryContext(ctx, prefix+query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// CopyAsJSON runs query and writes each row of the result to dst as a JSON object on its own line,
+// with the column names as keys, and returns the number of rows written.
+//
+// The values are written as they are received from Trino, without converting them to Go types,
+// so for example timestamps and decimals are strings, and maps and rows are JSON objects.
+func CopyAsJSON(ctx context.Context, dst io.Writer, db *sql.DB, query string, args ...interface{}) (int64, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var count int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, err := trinoConn(driverConn)
+		if err != nil {
+			return err
+		}
+		st := &driverStmt{conn: c, query: query}
+		defer st.Close()
+		namedArgs, err := st.namedValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err := st.QueryContext(ctx, namedArgs)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		qr := rows.(*driverRows)
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		for {
+			row, err := qr.nextRaw()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			buf.Reset()
+			buf.WriteByte('{')
+			for i, column := range qr.columns {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := enc.Encode(column); err != nil {
+					return err
+				}
+				// Encode terminates each value with a newline
+				buf.Truncate(buf.Len() - 1)
+				buf.WriteByte(':')
+				if err := enc.Encode(row[i]); err != nil {
+					return err
+				}
+				buf.Truncate(buf.Len() - 1)
+			}
+			buf.WriteString("}\n")
+			if _, err := dst.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			count++
+		}
+	})
+	return count, err
+}
+
+// namedValues converts args like database/sql does for the arguments of a query.
+func (st *driverStmt) namedValues(args []interface{}) ([]driver.NamedValue, error) {
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if named, ok := arg.(sql.NamedArg); ok {
+			namedArgs[i].Name = named.Name
+			namedArgs[i].Value = named.Value
+		}
+		err := st.CheckNamedValue(&namedArgs[i])
+		if err == driver.ErrSkip {
+			namedArgs[i].Value, err = driver.DefaultParameterConverter.ConvertValue(namedArgs[i].Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("trino: converting argument %d: %w", i+1, err)
+		}
+	}
+	return namedArgs, nil
+}
+
+// interpolateArgs replaces the ? placeholders of query with the serialized args.
+func interpolateArgs(query string, args []interface{}) (string, error) {
+	var b strings.Builder
+	next := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// copy the quoted string or identifier, where quotes are escaped by doubling them
+			end := i + 1
+			for end < len(query) {
+				if query[end] == c {
+					if end+1 < len(query) && query[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end == len(query) {
+				return "", fmt.Errorf("trino: unterminated quoted string in query")
+			}
+			b.WriteString(query[i : end+1])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i - 1
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", fmt.Errorf("trino: unterminated comment in query")
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case c == '?':
+			if next == len(args) {
+				return "", fmt.Errorf("trino: query has more placeholders than the %d arguments", len(args))
+			}
+			if _, ok := args[next].(sql.NamedArg); ok {
+				return "", fmt.Errorf("trino: named arguments are not supported when interpolating arguments")
+			}
+			s, err := Serial(args[next])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+			next++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if next != len(args) {
+		return "", fmt.Errorf("trino: query has %d placeholders but %d arguments", next, len(args))
+	}
+	return b.String(), nil
+}
+
+// Batch groups parameterized statements, like INSERT or UPDATE, to run them one after the other.
+type Batch struct {
+	db      *sql.DB
+	queries []string
+}
+
+// NewBatch returns an empty batch of statements to run on db.
+func NewBatch(db *sql.DB) *Batch {
+	return &Batch{db: db}
+}
+
+// Add adds a statement to the batch. The arguments are serialized immediately,
+// so unsupported arguments are reported by Add, before any statement runs.
+func (b *Batch) Add(query string, args ...interface{}) error {
+	if len(args) == 0 {
+		b.queries = append(b.queries, query)
+		return nil
+	}
+	ss := make([]string, len(args))
+	for i, arg := range args {
+		if _, ok := arg.(sql.NamedArg); ok {
+			return fmt.Errorf("trino: named arguments are not supported in a batch")
+		}
+		s, err := Serial(arg)
+		if err != nil {
+			return err
+		}
+		ss[i] = s
+	}
+	b.queries = append(b.queries, "EXECUTE IMMEDIATE "+formatStringLiteral(query)+" USING "+strings.Join(ss, ", "))
+	return nil
+}
+
+// Exec runs the statements of the batch on a single connection, in the order they were added,
+// and returns their results. It stops at the first statement that fails, returning the results
+// of the statements that ran before it.
+func (b *Batch) Exec(ctx context.Context) ([]sql.Result, error) {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	results := make([]sql.Result, 0, len(b.queries))
+	for i, query := range b.queries {
+		result, err := conn.ExecContext(ctx, query)
+		if err != nil {
+			return results, fmt.Errorf("trino: batch statement %d failed: %w", i, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// QueryBuilder builds a SELECT query with ? placeholders for its values, and the matching arguments,
+// so the number and order of the arguments always match the placeholders.
+//
+// Column and table names are added to the query as they are, so they must not come from untrusted input.
+type QueryBuilder struct {
+	columns    []string
+	table      string
+	conditions []string
+	args       []interface{}
+	limit      int
+	err        error
 }
 
-// registry for custom http clients
-var customClientRegistry = struct {
-	sync.RWMutex
-	Index map[string]http.Client
-}{
-	Index: make(map[string]http.Client),
+// queryBuilderOperators are the comparison operators supported by QueryBuilder.Where.
+var queryBuilderOperators = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "NOT LIKE": true,
 }
 
-// RegisterCustomClient associates a client to a key in the driver's registry.
-//
-// Register your custom client in the driver, then refer to it by name in the DSN, on the call to sql.Open:
-//
-//	foobarClient := &http.Client{
-//		Transport: &http.Transport{
-//			Proxy: http.ProxyFromEnvironment,
-//			DialContext: (&net.Dialer{
-//				Timeout:   30 * time.Second,
-//				KeepAlive: 30 * time.Second,
-//				DualStack: true,
-//			}).DialContext,
-//			MaxIdleConns:          100,
-//			IdleConnTimeout:       90 * time.Second,
-//			TLSHandshakeTimeout:   10 * time.Second,
-//			ExpectContinueTimeout: 1 * time.Second,
-//			TLSClientConfig:       &tls.Config{
-//			// your config here...
-//			},
-//		},
-//	}
-//	trino.RegisterCustomClient("foobar", foobarClient)
-//	db, err := sql.Open("trino", "https://user@localhost:8080?custom_client=foobar")
-func RegisterCustomClient(key string, client *http.Client) error {
-	if _, err := strconv.ParseBool(key); err == nil {
-		return fmt.Errorf("trino: custom client key %q is reserved", key)
+// NewQueryBuilder returns an empty query builder.
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{}
+}
+
+// Select adds columns to the query. If no columns are selected, the query selects all columns.
+func (b *QueryBuilder) Select(cols ...string) *QueryBuilder {
+	b.columns = append(b.columns, cols...)
+	return b
+}
+
+// From sets the table to query.
+func (b *QueryBuilder) From(table string) *QueryBuilder {
+	b.table = table
+	return b
+}
+
+// Where adds a condition comparing a column to a value, combined with the other conditions with AND.
+// The value is checked with Serial, so unsupported values are reported by Build.
+func (b *QueryBuilder) Where(col, op string, val interface{}) *QueryBuilder {
+	op = strings.ToUpper(strings.TrimSpace(op))
+	if !queryBuilderOperators[op] {
+		b.setErr(fmt.Errorf("trino: unsupported operator %q", op))
+		return b
+	}
+	if _, err := Serial(val); err != nil {
+		b.setErr(err)
+		return b
+	}
+	b.conditions = append(b.conditions, col+" "+op+" ?")
+	b.args = append(b.args, val)
+	return b
+}
+
+// Limit sets the maximum number of rows returned by the query.
+func (b *QueryBuilder) Limit(n int) *QueryBuilder {
+	if n < 0 {
+		b.setErr(fmt.Errorf("trino: invalid limit %d", n))
+		return b
 	}
-	customClientRegistry.Lock()
-	customClientRegistry.Index[key] = *client
-	customClientRegistry.Unlock()
-	return nil
+	b.limit = n
+	return b
 }
 
-// DeregisterCustomClient removes the client associated to the key.
-func DeregisterCustomClient(key string) {
-	customClientRegistry.Lock()
-	delete(customClientRegistry.Index, key)
-	customClientRegistry.Unlock()
+func (b *QueryBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
 }
 
-func getCustomClient(key string) *http.Client {
-	customClientRegistry.RLock()
-	defer customClientRegistry.RUnlock()
-	if client, ok := customClientRegistry.Index[key]; ok {
-		return &client
+// Build returns the query and its arguments, ready to be passed to db.Query,
+// or the first error reported while building it.
+func (b *QueryBuilder) Build() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
 	}
-	return nil
+	if b.table == "" {
+		return "", nil, fmt.Errorf("trino: query has no table")
+	}
+	columns := "*"
+	if len(b.columns) > 0 {
+		columns = strings.Join(b.columns, ", ")
+	}
+	query := "SELECT " + columns + " FROM " + b.table
+	if len(b.conditions) > 0 {
+		query += " WHERE " + strings.Join(b.conditions, " AND ")
+	}
+	if b.limit > 0 {
+		query += " LIMIT " + strconv.Itoa(b.limit)
+	}
+	return query, append([]interface{}(nil), b.args...), nil
 }
 
 // Begin implements the driver.Conn interface.
@@ -526,15 +2290,101 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 }
 
 // PrepareContext implements the driver.ConnPrepareContext interface.
+//
+// Statements are prepared by Trino when they are first executed, with the context of the execution,
+// so there is no request to cancel here, but an already cancelled or expired ctx is reported.
 func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return &driverStmt{conn: c, query: query}, nil
 }
 
+// ExecContext implements the driver.ExecerContext interface, so that database/sql executes
+// statements, like DDL statements without arguments, without preparing them first.
+func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	st := &driverStmt{conn: c, query: query}
+	defer st.Close()
+	return st.ExecContext(ctx, args)
+}
+
+// CheckNamedValue implements the driver.NamedValueChecker interface, accepting the same
+// arguments for statements executed on the connection as for prepared statements.
+func (c *Conn) CheckNamedValue(arg *driver.NamedValue) error {
+	return (&driverStmt{conn: c}).CheckNamedValue(arg)
+}
+
 // Close implements the driver.Conn interface.
+// It closes the idle network connections of the HTTP transport created for the connection, if any.
 func (c *Conn) Close() error {
+	if c.ownTransport != nil {
+		c.ownTransport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// setSessionRegexp matches SET SESSION statements assigning a literal value to a session property.
+var setSessionRegexp = regexp.MustCompile(`(?is)^\s*SET\s+SESSION\s+([a-z0-9_.]+)\s*=\s*('(?:[^']|'')*'|[a-z0-9_.+-]+)\s*;?\s*$`)
+
+// isSessionPropertySet returns true if query is a SET SESSION statement
+// for a value that Trino already set for the connection session.
+func (c *Conn) isSessionPropertySet(query string) bool {
+	m := setSessionRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return false
+	}
+	value := m[2]
+	if strings.HasPrefix(value, "'") {
+		value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	} else {
+		value = strings.ToLower(value)
+	}
+	current, ok := c.sessionCache[strings.ToLower(m[1])]
+	return ok && current == value
+}
+
+// TransactionID returns the ID of the Trino transaction started on the connection with a
+// START TRANSACTION query, or an empty string if there is none. Access the connection with
+// sql.Conn.Raw, and pass the ID with WithTransactionID to run queries in the transaction.
+func (c *Conn) TransactionID() string {
+	return c.httpHeaders.Get(trinoTransactionHeader)
+}
+
+// ResetSession implements the driver.SessionResetter interface. It is called before a
+// connection returned to the pool is reused, and stops using the transaction started on
+// the connection, so that it doesn't leak to unrelated queries. The transaction is not
+// rolled back and can still be used with WithTransactionID.
+//
+// It also forgets the session properties set on the connection, so that SET SESSION
+// statements of the next user are always sent to Trino.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	c.httpHeaders.Del(trinoTransactionHeader)
+	clear(c.sessionCache)
 	return nil
 }
 
+// Ping implements the driver.Pinger interface.
+// It runs a lightweight SELECT 1 query and discards its result.
+func (c *Conn) Ping(ctx context.Context) error {
+	st := &driverStmt{conn: c, query: "SELECT 1"}
+	defer st.Close()
+	rows, err := st.QueryContext(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		err = rows.Next(dest)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (c *Conn) newRequest(ctx context.Context, method, url string, body io.Reader, hs http.Header) (*http.Request, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
@@ -567,8 +2417,7 @@ func (c *Conn) newRequest(ctx context.Context, method, url string, body io.Reade
 }
 
 func (c *Conn) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
-	delay := 100 * time.Millisecond
-	const maxDelayBetweenRequests = float64(15 * time.Second)
+	delay := initialRetryDelay
 	timer := time.NewTimer(0)
 	defer timer.Stop()
 	for {
@@ -576,10 +2425,13 @@ func (c *Conn) roundTrip(ctx context.Context, req *http.Request) (*http.Response
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case <-timer.C:
+			c.log(ctx, slog.LevelDebug, "trino: sending request", "method", req.Method, "url", req.URL.String())
 			resp, err := c.httpClient.Do(req)
 			if err != nil {
+				c.log(ctx, slog.LevelWarn, "trino: request failed", "method", req.Method, "url", req.URL.String(), "error", err)
 				return nil, &ErrQueryFailed{Reason: err}
 			}
+			c.log(ctx, slog.LevelDebug, "trino: received response", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode)
 			switch resp.StatusCode {
 			case http.StatusOK:
 				for src, dst := range responseToRequestHeaderMap {
@@ -599,10 +2451,22 @@ func (c *Conn) roundTrip(ctx context.Context, req *http.Request) (*http.Response
 						}
 					}
 				}
+				if v := resp.Header.Get(trinoStartedTransactionHeader); v != "" {
+					c.httpHeaders.Set(trinoTransactionHeader, v)
+				}
+				if v := resp.Header.Get(trinoClearTransactionHeader); v != "" {
+					c.httpHeaders.Del(trinoTransactionHeader)
+				}
 				if v := resp.Header.Get(trinoSetSessionHeader); v != "" {
 					c.httpHeaders.Add(trinoSessionHeader, v)
+					if name, value, ok := strings.Cut(v, "="); ok {
+						if value, err := url.QueryUnescape(value); err == nil {
+							c.sessionCache[strings.ToLower(name)] = value
+						}
+					}
 				}
 				if v := resp.Header.Get(trinoClearSessionHeader); v != "" {
+					delete(c.sessionCache, strings.ToLower(v))
 					values := c.httpHeaders.Values(trinoSessionHeader)
 					c.httpHeaders.Del(trinoSessionHeader)
 					for _, v2 := range values {
@@ -618,30 +2482,98 @@ func (c *Conn) roundTrip(ctx context.Context, req *http.Request) (*http.Response
 				}
 				return resp, nil
 			case http.StatusServiceUnavailable:
-				resp.Body.Close()
+				drainAndClose(resp)
+				// send the request body again, instead of the one read by the failed attempt
+				if req.GetBody != nil {
+					if req.Body, err = req.GetBody(); err != nil {
+						return nil, &ErrQueryFailed{StatusCode: resp.StatusCode, Reason: err}
+					}
+				}
+				c.log(ctx, slog.LevelDebug, "trino: server unavailable, retrying request", "method", req.Method, "url", req.URL.String(), "delay", delay)
 				timer.Reset(delay)
-				delay = time.Duration(math.Min(
-					float64(delay)*math.Phi,
-					maxDelayBetweenRequests,
-				))
+				delay = nextRetryDelay(delay)
 				continue
 			default:
-				return nil, newErrQueryFailedFromResponse(resp)
+				err := newErrQueryFailedFromResponse(resp)
+				c.log(ctx, slog.LevelError, "trino: query failed", "method", req.Method, "url", req.URL.String(), "error", err)
+				return nil, err
 			}
 		}
 	}
 }
 
+const (
+	initialRetryDelay = 100 * time.Millisecond
+	maxRetryDelay     = 15 * time.Second
+
+	// resubmitting a query 10 times waits about 20 seconds for the server to start
+	defaultServerStartupRetries = 10
+)
+
+// nextRetryDelay returns the delay before the retry following one made after delay.
+func nextRetryDelay(delay time.Duration) time.Duration {
+	return time.Duration(math.Min(float64(delay)*math.Phi, float64(maxRetryDelay)))
+}
+
+// maxDrainBytes is the maximum number of bytes read from the rest of a response body before closing it.
+const maxDrainBytes = 4 * 1024
+
+// drainAndClose reads the rest of a response body, up to maxDrainBytes, and closes it,
+// so that the transport can reuse the connection for another request.
+func drainAndClose(resp *http.Response) error {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	return resp.Body.Close()
+}
+
+// log logs a message with the connection logger, if one is set.
+func (c *Conn) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if c.logger != nil {
+		c.logger.Log(ctx, level, msg, args...)
+	}
+}
+
+// decodeResponse decodes the JSON body of a server response into v,
+// reading at most the configured maximum response body size.
+func (c *Conn) decodeResponse(resp *http.Response, v interface{}) error {
+	var body io.Reader = resp.Body
+	if c.maxResponseBodySize > 0 {
+		body = http.MaxBytesReader(nil, resp.Body, c.maxResponseBodySize)
+	}
+	if c.readBufferSize > 0 {
+		body = bufio.NewReaderSize(body, c.readBufferSize)
+	}
+	d := json.NewDecoder(body)
+	d.UseNumber()
+	err := d.Decode(v)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Errorf("%w: limit is %d bytes", ErrResponseTooLarge, maxBytesErr.Limit)
+	}
+	if err != nil {
+		return fmt.Errorf("trino: %w", err)
+	}
+	return nil
+}
+
 // ErrQueryFailed indicates that a query to Trino failed.
 type ErrQueryFailed struct {
 	StatusCode int
 	Reason     error
+	// Query is the text of the failed query, only set if Config.TraceQueryText is true.
+	Query string
 }
 
+// maxTracedQueryLength is the maximum number of characters of the query text included in ErrQueryFailed.
+const maxTracedQueryLength = 4096
+
 // Error implements the error interface.
 func (e *ErrQueryFailed) Error() string {
-	return fmt.Sprintf("trino: query failed (%d %s): %q",
+	msg := fmt.Sprintf("trino: query failed (%d %s): %q",
 		e.StatusCode, http.StatusText(e.StatusCode), e.Reason)
+	if e.Query != "" {
+		msg += fmt.Sprintf(" query=%q", e.Query)
+	}
+	return msg
 }
 
 // Unwrap implements the unwrap interface.
@@ -649,6 +2581,12 @@ func (e *ErrQueryFailed) Unwrap() error {
 	return e.Reason
 }
 
+// HTTPStatus returns the HTTP status code of the response the query failed with,
+// like 429 when a gateway rate limits requests, or 0 if no response was received.
+func (e *ErrQueryFailed) HTTPStatus() int {
+	return e.StatusCode
+}
+
 func newErrQueryFailedFromResponse(resp *http.Response) *ErrQueryFailed {
 	const maxBytes = 8 * 1024
 	defer resp.Body.Close()
@@ -667,15 +2605,19 @@ func newErrQueryFailedFromResponse(resp *http.Response) *ErrQueryFailed {
 }
 
 type driverStmt struct {
-	conn           *Conn
-	query          string
-	user           string
-	nextURIs       chan string
-	httpResponses  chan *http.Response
-	queryResponses chan queryResponse
-	statsCh        chan QueryProgressInfo
-	errors         chan error
-	doneCh         chan struct{}
+	conn     *Conn
+	query    string
+	user     string
+	nextURIs chan string
+	// progressUpdater and progressUpdaterPeriod are set for each query, from the connection
+	// or the options of the query, so options attached with WrapContext don't outlive it.
+	progressUpdater       ProgressUpdater
+	progressUpdaterPeriod queryProgressCallbackPeriod
+	httpResponses         chan *http.Response
+	queryResponses        chan queryResponse
+	statsCh               chan QueryProgressInfo
+	errors                chan error
+	doneCh                chan struct{}
 }
 
 var (
@@ -695,14 +2637,18 @@ func (st *driverStmt) Close() error {
 		<-st.statsCh
 		st.statsCh = nil
 	}
+	errs := st.errors
 	go func() {
 		// drain errors chan to allow goroutines to write to it
-		for range st.errors {
+		for range errs {
 		}
 	}()
 	for range st.queryResponses {
 	}
-	for range st.httpResponses {
+	for resp := range st.httpResponses {
+		if resp != nil {
+			drainAndClose(resp)
+		}
 	}
 	close(st.nextURIs)
 	close(st.errors)
@@ -719,19 +2665,11 @@ func (st *driverStmt) Exec(args []driver.Value) (driver.Result, error) {
 }
 
 func (st *driverStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	sr, err := st.exec(ctx, args)
-	if err != nil {
-		return nil, err
-	}
-	rows := &driverRows{
-		ctx:          ctx,
-		stmt:         st,
-		queryID:      sr.ID,
-		nextURI:      sr.NextURI,
-		rowsAffected: sr.UpdateCount,
-		statsCh:      st.statsCh,
-		doneCh:       st.doneCh,
+	// skip setting a session property to the value it already has
+	if len(args) == 0 && st.conn.isSessionPropertySet(st.query) {
+		return driver.RowsAffected(0), nil
 	}
+	rows, err := st.start(ctx, args)
 	// consume all results, if there are any
 	for err == nil {
 		err = rows.fetch()
@@ -743,15 +2681,73 @@ func (st *driverStmt) ExecContext(ctx context.Context, args []driver.NamedValue)
 	return rows, nil
 }
 
+// queryError adds details about the query to an ErrQueryFailed: its text, if enabled in the connection
+// configuration, and ErrLockingNotSupported, if it failed because of a locking clause.
+func (st *driverStmt) queryError(err error) error {
+	var qferr *ErrQueryFailed
+	if !errors.As(err, &qferr) {
+		return err
+	}
+	if isLockingClauseError(st.query, qferr.Reason) {
+		qferr.Reason = fmt.Errorf("%w: %w", ErrLockingNotSupported, qferr.Reason)
+	}
+	if st.conn.traceQueryText {
+		query := []rune(st.query)
+		if len(query) > maxTracedQueryLength {
+			query = query[:maxTracedQueryLength]
+		}
+		qferr.Query = string(query)
+	}
+	return err
+}
+
+// lockingClauseRegexp matches the row locking clauses of databases like PostgreSQL and MySQL.
+var lockingClauseRegexp = regexp.MustCompile(`(?i)\bFOR\s+(?:NO\s+KEY\s+)?UPDATE\b|\bFOR\s+(?:KEY\s+)?SHARE\b`)
+
+// quotedRegexp matches string literals and quoted identifiers.
+var quotedRegexp = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"`)
+
+// isLockingClauseError returns true if err is the error Trino returns for a locking clause of query,
+// either a syntax error at the FOR keyword or a NOT_SUPPORTED error mentioning the clause.
+func isLockingClauseError(query string, err error) bool {
+	var trinoErr *ErrTrino
+	if !errors.As(err, &trinoErr) || !lockingClauseRegexp.MatchString(quotedRegexp.ReplaceAllString(query, "''")) {
+		return false
+	}
+	switch trinoErr.ErrorName {
+	case "SYNTAX_ERROR":
+		return strings.Contains(trinoErr.Message, "'FOR'")
+	case "NOT_SUPPORTED":
+		return lockingClauseRegexp.MatchString(trinoErr.Message)
+	}
+	return false
+}
+
 func (st *driverStmt) CheckNamedValue(arg *driver.NamedValue) error {
+	if valuer, ok := arg.Value.(driver.Valuer); ok {
+		value, err := callValuer(valuer)
+		if err != nil {
+			return err
+		}
+		arg.Value = value
+	}
 	switch arg.Value.(type) {
 	case nil:
 		return nil
-	case Numeric, trinoDate, trinoTime, trinoTimeTz, trinoTimestamp, time.Duration:
+	case Numeric, *big.Int, trinoDate, trinoTime, trinoTimeTz, trinoTimestamp, trinoRow, trinoMap, time.Duration:
+		return nil
+	case *trinoDate, *trinoTime, *trinoTimeTz, *trinoTimestamp:
+		// nil pointers are sent as NULL
+		if v := reflect.ValueOf(arg.Value); v.IsNil() {
+			arg.Value = nil
+		} else {
+			arg.Value = v.Elem().Interface()
+		}
 		return nil
 	default:
 		{
-			if reflect.TypeOf(arg.Value).Kind() == reflect.Slice {
+			switch reflect.TypeOf(arg.Value).Kind() {
+			case reflect.Slice, reflect.Struct:
 				return nil
 			}
 
@@ -768,13 +2764,14 @@ func (st *driverStmt) CheckNamedValue(arg *driver.NamedValue) error {
 }
 
 type stmtResponse struct {
-	ID          string    `json:"id"`
-	InfoURI     string    `json:"infoUri"`
-	NextURI     string    `json:"nextUri"`
-	Stats       stmtStats `json:"stats"`
-	Error       ErrTrino  `json:"error"`
-	UpdateType  string    `json:"updateType"`
-	UpdateCount int64     `json:"updateCount"`
+	ID          string         `json:"id"`
+	InfoURI     string         `json:"infoUri"`
+	NextURI     string         `json:"nextUri"`
+	Stats       stmtStats      `json:"stats"`
+	Error       ErrTrino       `json:"error"`
+	UpdateType  string         `json:"updateType"`
+	UpdateCount int64          `json:"updateCount"`
+	Warnings    []TrinoWarning `json:"warnings"`
 }
 
 type stmtStats struct {
@@ -801,6 +2798,26 @@ type stmtStats struct {
 	RunningPercentage    jsonFloat64 `json:"runningPercentage"`
 }
 
+// ElapsedTime returns the elapsed time of the query.
+func (s stmtStats) ElapsedTime() time.Duration {
+	return time.Duration(s.ElapsedTimeMillis) * time.Millisecond
+}
+
+// QueuedTime returns the time the query spent queued.
+func (s stmtStats) QueuedTime() time.Duration {
+	return time.Duration(s.QueuedTimeMillis) * time.Millisecond
+}
+
+// CPUTime returns the CPU time used by the query.
+func (s stmtStats) CPUTime() time.Duration {
+	return time.Duration(s.CPUTimeMillis) * time.Millisecond
+}
+
+// WallTime returns the wall time used by the query.
+func (s stmtStats) WallTime() time.Duration {
+	return time.Duration(s.WallTimeMillis) * time.Millisecond
+}
+
 type ErrTrino struct {
 	Message       string        `json:"message"`
 	SqlState      string        `json:"sqlState"`
@@ -815,6 +2832,104 @@ func (i ErrTrino) Error() string {
 	return i.ErrorType + ": " + i.Message
 }
 
+// TrinoErrorByName returns an error with only a name, to compare Trino errors by name with errors.Is,
+// such as errors.Is(err, trino.TrinoErrorByName("CATALOG_NOT_FOUND")).
+func TrinoErrorByName(name string) *ErrTrino {
+	return &ErrTrino{ErrorName: name}
+}
+
+// Is returns true if target is an *ErrTrino with the same error name.
+func (i *ErrTrino) Is(target error) bool {
+	t, ok := target.(*ErrTrino)
+	return ok && t != nil && t.ErrorName == i.ErrorName
+}
+
+// FailureChain returns the failure info of the error followed by its causes,
+// from the outermost to the innermost one.
+// It returns nil if the error has no failure info.
+func (i *ErrTrino) FailureChain() []*FailureInfo {
+	if i.FailureInfo.Type == "" && i.FailureInfo.Cause == nil {
+		return nil
+	}
+	var chain []*FailureInfo
+	for info := &i.FailureInfo; info != nil; info = info.Cause {
+		chain = append(chain, info)
+	}
+	return chain
+}
+
+// RootCause returns the innermost cause of the error failure info,
+// or nil if the error has no failure info.
+func (i *ErrTrino) RootCause() *FailureInfo {
+	chain := i.FailureChain()
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain[len(chain)-1]
+}
+
+// TrinoWarning is a warning reported by Trino for a query, for example about deprecated syntax.
+type TrinoWarning struct {
+	WarningCode WarningCode `json:"warningCode"`
+	Message     string      `json:"message"`
+	Level       string      `json:"level"`
+}
+
+// WarningCode identifies the kind of a TrinoWarning.
+type WarningCode struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+}
+
+// WarningCollector collects the warnings reported by Trino for queries run with
+// a context returned by WithWarningCollector.
+type WarningCollector struct {
+	mu       sync.Mutex
+	warnings []TrinoWarning
+	seen     map[TrinoWarning]bool
+}
+
+type warningCollectorKey struct{}
+
+// WithWarningCollector returns a copy of ctx that collects the warnings reported by Trino
+// for queries run with it, and the collector to read them from.
+//
+// Warnings are collected as responses are received, so all of them are available
+// once the rows returned by a query are read or closed. Each distinct warning is
+// collected once, even if it's reported again by later responses.
+func WithWarningCollector(ctx context.Context) (context.Context, *WarningCollector) {
+	collector := &WarningCollector{}
+	return context.WithValue(ctx, warningCollectorKey{}, collector), collector
+}
+
+// Warnings returns the warnings collected so far.
+func (c *WarningCollector) Warnings() []TrinoWarning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]TrinoWarning(nil), c.warnings...)
+}
+
+// collectWarnings adds the warnings of a response to the collector in ctx, if any.
+// Trino returns all the warnings of a query so far in every response, so the ones
+// already collected are skipped.
+func collectWarnings(ctx context.Context, warnings []TrinoWarning) {
+	collector, ok := ctx.Value(warningCollectorKey{}).(*WarningCollector)
+	if !ok || len(warnings) == 0 {
+		return
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.seen == nil {
+		collector.seen = make(map[TrinoWarning]bool)
+	}
+	for _, warning := range warnings {
+		if !collector.seen[warning] {
+			collector.seen[warning] = true
+			collector.warnings = append(collector.warnings, warning)
+		}
+	}
+}
+
 type ErrorLocation struct {
 	LineNumber   int `json:"lineNumber"`
 	ColumnNumber int `json:"columnNumber"`
@@ -884,39 +2999,111 @@ func (st *driverStmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (st *driverStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	sr, err := st.exec(ctx, args)
-	if err != nil {
-		return nil, err
-	}
-	rows := &driverRows{
-		ctx:     ctx,
-		stmt:    st,
-		queryID: sr.ID,
-		nextURI: sr.NextURI,
-		statsCh: st.statsCh,
-		doneCh:  st.doneCh,
-	}
-	if err = rows.fetch(); err != nil && err != io.EOF {
+	rows, err := st.start(ctx, args)
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
 	return rows, nil
 }
 
+// errServerStartingUp is the error of queries submitted to a server that is starting up.
+var errServerStartingUp = TrinoErrorByName("SERVER_STARTING_UP")
+
+// start submits the query and fetches its first results, which may be io.EOF. Queries failing because
+// the server is starting up are submitted again, up to the number of retries configured for the connection.
+func (st *driverStmt) start(ctx context.Context, args []driver.NamedValue) (*driverRows, error) {
+	delay := initialRetryDelay
+	for retries := 0; ; retries++ {
+		started := time.Now()
+		sr, err := st.exec(ctx, args)
+		if st.conn.metrics != nil && sr != nil && sr.ID != "" && err != nil {
+			st.conn.metrics.OnQueryEnd(sr.ID, time.Since(started), 0, err)
+		}
+		var rows *driverRows
+		if err == nil {
+			rows = &driverRows{
+				ctx:          ctx,
+				stmt:         st,
+				queryID:      sr.ID,
+				nextURI:      sr.NextURI,
+				rowsAffected: sr.UpdateCount,
+				started:      started,
+				statsCh:      st.statsCh,
+				doneCh:       st.doneCh,
+			}
+			err = rows.fetch()
+		}
+		if err == nil || err == io.EOF || !errors.Is(err, errServerStartingUp) || retries >= st.conn.serverStartupRetries {
+			return rows, err
+		}
+		// stop the goroutines of the failed query before submitting it again
+		st.Close()
+		st.conn.log(ctx, slog.LevelDebug, "trino: server starting up, retrying query", "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay = nextRetryDelay(delay)
+	}
+}
+
 func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmtResponse, error) {
 	query := st.query
 	hs := make(http.Header)
 	// Ensure the server returns timestamps preserving their precision, without truncating them to timestamp(3).
 	hs.Add("X-Trino-Client-Capabilities", "PARAMETRIC_DATETIME")
+	if txID, ok := ctx.Value(transactionIDKey{}).(string); ok && txID != "" {
+		hs.Set(trinoTransactionHeader, txID)
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		hs.Set(trinoClientRequestIDHeader, id)
+	}
+	if token, ok := ctx.Value(traceTokenKey{}).(string); ok && token != "" {
+		hs.Set(trinoTraceTokenHeader, token)
+	}
+	if tags, ok := ctx.Value(queryTagsKey{}).([]string); ok && len(tags) > 0 {
+		for _, tag := range tags {
+			if strings.Contains(tag, ",") {
+				return nil, fmt.Errorf("trino: client tag %q cannot contain commas", tag)
+			}
+		}
+		hs.Set(trinoClientTagsHeader, strings.Join(tags, ","))
+	}
+	if props, ok := ctx.Value(sessionPropertiesKey{}).(map[string]string); ok && len(props) > 0 {
+		values, err := st.conn.sessionHeaderValues(props)
+		if err != nil {
+			return nil, err
+		}
+		hs[trinoSessionHeader] = values
+	}
+	progressUpdater, progressUpdaterPeriod := st.conn.progressUpdater, st.conn.progressUpdaterPeriod.Period
+	if opts, ok := ctx.Value(queryOptionsKey{}).(trinoQueryOptions); ok {
+		if opts.user != "" {
+			st.user = opts.user
+			hs.Set(trinoUserHeader, opts.user)
+		}
+		if opts.progressUpdater != nil || opts.progressUpdaterPeriod != 0 {
+			if opts.progressUpdater == nil || opts.progressUpdaterPeriod <= 0 {
+				return nil, ErrInvalidProgressCallbackHeader
+			}
+			progressUpdater, progressUpdaterPeriod = opts.progressUpdater, opts.progressUpdaterPeriod
+		}
+	}
 
 	if len(args) > 0 {
 		var ss []string
 		for _, arg := range args {
 			if arg.Name == trinoProgressCallbackParam {
 				st.conn.progressUpdater = arg.Value.(ProgressUpdater)
+				progressUpdater = st.conn.progressUpdater
 				continue
 			}
 			if arg.Name == trinoProgressCallbackPeriodParam {
 				st.conn.progressUpdaterPeriod.Period = arg.Value.(time.Duration)
+				progressUpdaterPeriod = st.conn.progressUpdaterPeriod.Period
 				continue
 			}
 
@@ -936,6 +3123,8 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 
 				if arg.Name == trinoUserHeader {
 					st.user = headerValue
+					hs.Set(arg.Name, headerValue)
+					continue
 				}
 
 				hs.Add(arg.Name, headerValue)
@@ -949,7 +3138,7 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 				ss = append(ss, s)
 			}
 		}
-		if (st.conn.progressUpdater != nil && st.conn.progressUpdaterPeriod.Period == 0) || (st.conn.progressUpdater == nil && st.conn.progressUpdaterPeriod.Period > 0) {
+		if (progressUpdater != nil && progressUpdaterPeriod == 0) || (progressUpdater == nil && progressUpdaterPeriod > 0) {
 			return nil, ErrInvalidProgressCallbackHeader
 		}
 		if len(ss) > 0 {
@@ -961,9 +3150,16 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 		}
 	}
 
+	st.progressUpdater = progressUpdater
+	st.progressUpdaterPeriod = queryProgressCallbackPeriod{Period: progressUpdaterPeriod}
+
 	var cancel context.CancelFunc = func() {}
 	if _, ok := ctx.Deadline(); !ok {
-		ctx, cancel = context.WithTimeout(ctx, DefaultQueryTimeout)
+		timeout := DefaultQueryTimeout
+		if st.conn.queryTimeout > 0 {
+			timeout = st.conn.queryTimeout
+		}
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	}
 	req, err := st.conn.newRequest(ctx, "POST", st.conn.baseURL+"/v1/statement", strings.NewReader(query), hs)
 	if err != nil {
@@ -971,21 +3167,38 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 		return nil, err
 	}
 
+	requested := time.Now()
 	resp, err := st.conn.roundTrip(ctx, req)
 	if err != nil {
 		cancel()
-		return nil, err
+		return nil, st.queryError(err)
 	}
 
-	defer resp.Body.Close()
 	var sr stmtResponse
-	d := json.NewDecoder(resp.Body)
-	d.UseNumber()
-	err = d.Decode(&sr)
+	if metrics := st.conn.metrics; metrics != nil {
+		// reported once the response is drained, after the query start
+		resp.Body = &meteredBody{ReadCloser: resp.Body, closed: func(n int64) {
+			if sr.ID != "" {
+				metrics.OnSegmentDownload(sr.ID, inlineSegmentType, n, time.Since(requested))
+			}
+		}}
+	}
+	defer drainAndClose(resp)
+	err = st.conn.decodeResponse(resp, &sr)
 	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("trino: %w", err)
+		return nil, err
+	}
+	if st.conn.metrics != nil && sr.ID != "" {
+		st.conn.metrics.OnQueryStart(sr.ID)
 	}
+	if queryID, ok := ctx.Value(queryIDKey{}).(*string); ok {
+		*queryID = sr.ID
+	}
+	if uri, ok := ctx.Value(queryInfoURIKey{}).(*string); ok && st.conn.enableQueryInfo {
+		*uri = sr.InfoURI
+	}
+	collectWarnings(ctx, sr.Warnings)
 
 	st.doneCh = make(chan struct{})
 	st.nextURIs = make(chan string)
@@ -1011,6 +3224,7 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 					st.errors <- err
 					return
 				}
+				requested := time.Now()
 				resp, err := st.conn.roundTrip(ctx, req)
 				if err != nil {
 					if ctx.Err() == context.Canceled {
@@ -1020,9 +3234,15 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 					st.errors <- err
 					return
 				}
+				if metrics := st.conn.metrics; metrics != nil {
+					resp.Body = &meteredBody{ReadCloser: resp.Body, closed: func(n int64) {
+						metrics.OnSegmentDownload(sr.ID, inlineSegmentType, n, time.Since(requested))
+					}}
+				}
 				select {
 				case st.httpResponses <- resp:
 				case <-st.doneCh:
+					drainAndClose(resp)
 					return
 				}
 			case <-st.doneCh:
@@ -1040,14 +3260,13 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 					return
 				}
 				var qresp queryResponse
-				d := json.NewDecoder(resp.Body)
-				d.UseNumber()
-				err = d.Decode(&qresp)
+				err = st.conn.decodeResponse(resp, &qresp)
 				if err != nil {
-					st.errors <- fmt.Errorf("trino: %w", err)
+					st.errors <- err
 					return
 				}
-				err = resp.Body.Close()
+				collectWarnings(ctx, qresp.Warnings)
+				err = drainAndClose(resp)
 				if err != nil {
 					st.errors <- err
 					return
@@ -1073,7 +3292,7 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 		}
 	}()
 	st.nextURIs <- sr.NextURI
-	if st.conn.progressUpdater != nil {
+	if st.progressUpdater != nil {
 		st.statsCh = make(chan QueryProgressInfo)
 
 		// progress updater go func
@@ -1081,7 +3300,7 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 			for {
 				select {
 				case stats := <-st.statsCh:
-					st.conn.progressUpdater.Update(stats)
+					st.progressUpdater.Update(stats)
 				case <-st.doneCh:
 					close(st.statsCh)
 					return
@@ -1091,6 +3310,7 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 
 		// initial progress callback call
 		srStats := QueryProgressInfo{
+			QueryID:    sr.ID,
 			QueryId:    sr.ID,
 			QueryStats: sr.Stats,
 		}
@@ -1099,10 +3319,10 @@ func (st *driverStmt) exec(ctx context.Context, args []driver.NamedValue) (*stmt
 		default:
 			// ignore when can't send stats
 		}
-		st.conn.progressUpdaterPeriod.LastCallbackTime = time.Now()
-		st.conn.progressUpdaterPeriod.LastQueryState = sr.Stats.State
+		st.progressUpdaterPeriod.LastCallbackTime = time.Now()
+		st.progressUpdaterPeriod.LastQueryState = sr.Stats.State
 	}
-	return &sr, handleResponseError(resp.StatusCode, sr.Error)
+	return &sr, st.queryError(handleResponseError(resp.StatusCode, sr.Error))
 }
 
 func formatStringLiteral(query string) string {
@@ -1122,6 +3342,10 @@ type driverRows struct {
 	data         []queryData
 	rowsAffected int64
 
+	started  time.Time
+	rowsRead int64
+	finished bool
+
 	statsCh chan QueryProgressInfo
 	doneCh  chan struct{}
 }
@@ -1132,10 +3356,13 @@ var _ driver.RowsColumnTypeScanType = &driverRows{}
 var _ driver.RowsColumnTypeDatabaseTypeName = &driverRows{}
 var _ driver.RowsColumnTypeLength = &driverRows{}
 var _ driver.RowsColumnTypePrecisionScale = &driverRows{}
+var _ driver.RowsColumnTypeNullable = &driverRows{}
+var _ driver.RowsNextResultSet = &driverRows{}
 
 // Close closes the rows iterator.
 func (qr *driverRows) Close() error {
-	if qr.err == sql.ErrNoRows || qr.err == io.EOF {
+	qr.finish(nil)
+	if qr.err == io.EOF {
 		return nil
 	}
 	qr.err = io.EOF
@@ -1158,7 +3385,7 @@ func (qr *driverRows) Close() error {
 		}
 		return err
 	}
-	resp.Body.Close()
+	drainAndClose(resp)
 	return qr.err
 }
 
@@ -1176,12 +3403,83 @@ func (qr *driverRows) Columns() []string {
 	return qr.columns
 }
 
+// ColumnTypeDatabaseTypeName returns the database type name of the column,
+// including its parameters, such as DECIMAL(10,5) or TIMESTAMP(6) WITH TIME ZONE.
+// The names of ROW fields are returned as sent by Trino.
 func (qr *driverRows) ColumnTypeDatabaseTypeName(index int) string {
-	typeName := qr.coltype[index].parsedType[0]
-	if typeName == "map" || typeName == "array" || typeName == "row" {
-		typeName = qr.coltype[index].typeName
+	return upperTypeKeywords(qr.coltype[index].typeName)
+}
+
+// upperTypeKeywords upper-cases the keywords of a type name, leaving the names of
+// row fields and quoted identifiers unchanged.
+func upperTypeKeywords(typeName string) string {
+	var b strings.Builder
+	for i := 0; i < len(typeName); {
+		switch c := typeName[i]; {
+		case c == '"':
+			j := i + 1
+			for j < len(typeName) {
+				if typeName[j] == '"' {
+					if j+1 < len(typeName) && typeName[j+1] == '"' {
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				j++
+			}
+			b.WriteString(typeName[i:j])
+			i = j
+		case isIdentifierChar(c):
+			j := i
+			for j < len(typeName) && isIdentifierChar(typeName[j]) {
+				j++
+			}
+			if isRowFieldName(typeName, i, j) {
+				b.WriteString(typeName[i:j])
+			} else {
+				b.WriteString(strings.ToUpper(typeName[i:j]))
+			}
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// isRowFieldName returns true if the word between start and end in a type name is the name of
+// a row field, which is the first word of a parameter followed by the type of the field.
+func isRowFieldName(typeName string, start, end int) bool {
+	before := strings.TrimRight(typeName[:start], " ")
+	if !strings.HasSuffix(before, "(") && !strings.HasSuffix(before, ",") {
+		return false
+	}
+	after := strings.TrimLeft(typeName[end:], " ")
+	if len(after) == len(typeName[end:]) || after == "" || after[0] != '"' && !isIdentifierChar(after[0]) {
+		return false
+	}
+	// types made of several words, like an anonymous field of type TIME WITH TIME ZONE
+	next := after
+	if i := strings.IndexFunc(next, func(r rune) bool { return r > unicode.MaxASCII || !isIdentifierChar(byte(r)) }); i >= 0 {
+		next = next[:i]
+	}
+	next = strings.ToLower(next)
+	switch strings.ToLower(typeName[start:end]) {
+	case "time", "timestamp":
+		return next != "with" && next != "without"
+	case "interval":
+		return next != "day" && next != "year"
+	case "double":
+		return next != "precision"
 	}
-	return strings.ToUpper(typeName)
+	return true
 }
 
 func (qr *driverRows) ColumnTypeScanType(index int) reflect.Type {
@@ -1192,46 +3490,78 @@ func (qr *driverRows) ColumnTypeLength(index int) (int64, bool) {
 	return qr.coltype[index].size.value, qr.coltype[index].size.hasValue
 }
 
+// ColumnTypePrecisionScale returns the precision and scale of DECIMAL columns, and the
+// precision of the fractional seconds of TIME and TIMESTAMP columns, with a scale of zero.
 func (qr *driverRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
 	return qr.coltype[index].precision.value, qr.coltype[index].scale.value, qr.coltype[index].precision.hasValue
 }
 
+// ColumnTypeNullable reports that every column is nullable,
+// since Trino doesn't expose NOT NULL constraints in query results.
+func (qr *driverRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return true, true
+}
+
 // Next is called to populate the next row of data into
 // the provided slice. The provided slice will be the same
 // size as the Columns() are wide.
 //
 // Next should return io.EOF when there are no more rows.
 func (qr *driverRows) Next(dest []driver.Value) error {
+	row, err := qr.nextRaw()
+	if err != nil {
+		return err
+	}
+	for i, v := range qr.coltype {
+		if i > len(dest)-1 {
+			break
+		}
+		vv, err := v.ConvertValue(row[i])
+		if err != nil {
+			qr.err = err
+			return err
+		}
+		dest[i] = vv
+	}
+	return nil
+}
+
+// nextRaw returns the next row as decoded from the JSON response, without converting its values.
+func (qr *driverRows) nextRaw() (queryData, error) {
 	if qr.err != nil {
-		return qr.err
+		return nil, qr.err
 	}
 	if qr.columns == nil || qr.rowindex >= len(qr.data) {
 		if qr.nextURI == "" {
 			qr.err = io.EOF
-			return qr.err
+			return nil, qr.err
 		}
 		if err := qr.fetch(); err != nil {
 			qr.err = err
-			return err
+			return nil, err
 		}
 	}
 	if len(qr.coltype) == 0 {
-		qr.err = sql.ErrNoRows
-		return qr.err
-	}
-	for i, v := range qr.coltype {
-		if i > len(dest)-1 {
-			break
-		}
-		vv, err := v.ConvertValue(qr.data[qr.rowindex][i])
-		if err != nil {
-			qr.err = err
-			return err
-		}
-		dest[i] = vv
+		// statements without columns have no rows, which database/sql expects to be reported as io.EOF
+		qr.err = io.EOF
+		return nil, qr.err
 	}
+	row := qr.data[qr.rowindex]
 	qr.rowindex++
-	return nil
+	return row, nil
+}
+
+// HasNextResultSet implements the driver.RowsNextResultSet interface.
+// Trino returns a single result set per query, so the pages fetched
+// by following the next URI are always part of the current result set.
+func (qr *driverRows) HasNextResultSet() bool {
+	return false
+}
+
+// NextResultSet implements the driver.RowsNextResultSet interface.
+// It returns io.EOF, since there are no more result sets.
+func (qr *driverRows) NextResultSet() error {
+	return io.EOF
 }
 
 // LastInsertId returns the database's auto-generated ID
@@ -1247,16 +3577,17 @@ func (qr driverRows) RowsAffected() (int64, error) {
 }
 
 type queryResponse struct {
-	ID               string        `json:"id"`
-	InfoURI          string        `json:"infoUri"`
-	PartialCancelURI string        `json:"partialCancelUri"`
-	NextURI          string        `json:"nextUri"`
-	Columns          []queryColumn `json:"columns"`
-	Data             []queryData   `json:"data"`
-	Stats            stmtStats     `json:"stats"`
-	Error            ErrTrino      `json:"error"`
-	UpdateType       string        `json:"updateType"`
-	UpdateCount      int64         `json:"updateCount"`
+	ID               string         `json:"id"`
+	InfoURI          string         `json:"infoUri"`
+	PartialCancelURI string         `json:"partialCancelUri"`
+	NextURI          string         `json:"nextUri"`
+	Columns          []queryColumn  `json:"columns"`
+	Data             []queryData    `json:"data"`
+	Stats            stmtStats      `json:"stats"`
+	Error            ErrTrino       `json:"error"`
+	UpdateType       string         `json:"updateType"`
+	UpdateCount      int64          `json:"updateCount"`
+	Warnings         []TrinoWarning `json:"warnings"`
 }
 
 type queryColumn struct {
@@ -1323,14 +3654,17 @@ func (qr *driverRows) fetch() error {
 		select {
 		case qresp = <-qr.stmt.queryResponses:
 			if qresp.ID == "" {
+				qr.finish(io.EOF)
 				return io.EOF
 			}
 			err = qr.initColumns(&qresp)
 			if err != nil {
+				qr.finish(err)
 				return err
 			}
 			qr.rowindex = 0
 			qr.data = qresp.Data
+			qr.rowsRead += int64(len(qresp.Data))
 			qr.rowsAffected = qresp.UpdateCount
 			qr.scheduleProgressUpdate(qresp.ID, qresp.Stats)
 			if len(qr.data) != 0 {
@@ -1342,14 +3676,30 @@ func (qr *driverRows) fetch() error {
 				// or rows were closed.
 				err = io.EOF
 			} else if err == context.Canceled {
+				qr.finish(err)
 				qr.Close()
+			} else {
+				err = qr.stmt.queryError(err)
 			}
 			qr.err = err
+			qr.finish(err)
 			return err
 		}
 	}
 }
 
+// finish reports the end of the query to the metrics callback of the connection, if it's the first call.
+func (qr *driverRows) finish(err error) {
+	if qr.finished || qr.stmt.conn.metrics == nil {
+		return
+	}
+	qr.finished = true
+	if err == io.EOF {
+		err = nil
+	}
+	qr.stmt.conn.metrics.OnQueryEnd(qr.queryID, time.Since(qr.started), qr.rowsRead, err)
+}
+
 func unmarshalArguments(signature *typeSignature) error {
 	for i, argument := range signature.Arguments {
 		var payload interface{}
@@ -1401,25 +3751,29 @@ func (qr *driverRows) initColumns(qresp *queryResponse) error {
 		if err != nil {
 			return err
 		}
+		if qr.stmt.conn.rowValues {
+			qr.coltype[i].setRowValues()
+		}
 	}
 	return nil
 }
 
 func (qr *driverRows) scheduleProgressUpdate(id string, stats stmtStats) {
-	if qr.stmt.conn.progressUpdater == nil {
+	if qr.stmt.progressUpdater == nil {
 		return
 	}
 
 	qrStats := QueryProgressInfo{
+		QueryID:    id,
 		QueryId:    id,
 		QueryStats: stats,
 	}
 	currentTime := time.Now()
-	diff := currentTime.Sub(qr.stmt.conn.progressUpdaterPeriod.LastCallbackTime)
-	period := qr.stmt.conn.progressUpdaterPeriod.Period
+	diff := currentTime.Sub(qr.stmt.progressUpdaterPeriod.LastCallbackTime)
+	period := qr.stmt.progressUpdaterPeriod.Period
 
 	// Check if period has not passed yet AND if query state did not change
-	if diff < period && qr.stmt.conn.progressUpdaterPeriod.LastQueryState == qrStats.QueryStats.State {
+	if diff < period && qr.stmt.progressUpdaterPeriod.LastQueryState == qrStats.QueryStats.State {
 		return
 	}
 
@@ -1428,8 +3782,8 @@ func (qr *driverRows) scheduleProgressUpdate(id string, stats stmtStats) {
 	default:
 		// ignore when can't send stats
 	}
-	qr.stmt.conn.progressUpdaterPeriod.LastCallbackTime = currentTime
-	qr.stmt.conn.progressUpdaterPeriod.LastQueryState = qrStats.QueryStats.State
+	qr.stmt.progressUpdaterPeriod.LastCallbackTime = currentTime
+	qr.stmt.progressUpdaterPeriod.LastQueryState = qrStats.QueryStats.State
 }
 
 type typeConverter struct {
@@ -1439,6 +3793,17 @@ type typeConverter struct {
 	precision  optionalInt64
 	scale      optionalInt64
 	size       optionalInt64
+	// fields of a ROW type, in the order in which their values are returned
+	fields []rowField
+	// rowValues makes the elements of an ARRAY(ROW) type converted to RowValue
+	rowValues bool
+	// elements of an ARRAY(ROW) or ARRAY(MAP) type
+	element *typeConverter
+}
+
+type rowField struct {
+	name      string
+	converter *typeConverter
 }
 
 type optionalInt64 struct {
@@ -1467,6 +3832,9 @@ func newTypeConverter(typeName string, signature typeSignature) (*typeConverter,
 				return nil, ErrInvalidResponseType
 			}
 			result.size = newOptionalInt64(signature.Arguments[0].long)
+		} else if signature.RawType == "char" {
+			// CHAR without a length is CHAR(1)
+			result.size = newOptionalInt64(1)
 		}
 	case "decimal":
 		if len(signature.Arguments) > 0 {
@@ -1481,18 +3849,64 @@ func newTypeConverter(typeName string, signature typeSignature) (*typeConverter,
 			}
 			result.scale = newOptionalInt64(signature.Arguments[1].long)
 		}
-	case "time", "time with time zone", "timestamp", "timestamp with time zone":
+	case "time", "time with time zone", "timestamp", "timestamp with time zone", "timestamp with local time zone":
 		if len(signature.Arguments) > 0 {
 			if signature.Arguments[0].Kind != KIND_LONG {
 				return nil, ErrInvalidResponseType
 			}
 			result.precision = newOptionalInt64(signature.Arguments[0].long)
 		}
+	case "row":
+		result.fields = make([]rowField, len(signature.Arguments))
+		for i, argument := range signature.Arguments {
+			if argument.Kind != KIND_NAMED_TYPE {
+				return nil, ErrInvalidResponseType
+			}
+			fieldSignature := argument.namedTypeSignature.TypeSignature
+			converter, err := newTypeConverter(fieldSignature.RawType, fieldSignature)
+			if err != nil {
+				return nil, err
+			}
+			result.fields[i] = rowField{
+				name:      argument.namedTypeSignature.FieldName.Name,
+				converter: converter,
+			}
+		}
+	case "map":
+		if len(signature.Arguments) == 2 &&
+			signature.Arguments[0].Kind == KIND_TYPE && signature.Arguments[0].typeSignature.RawType == "varchar" &&
+			signature.Arguments[1].Kind == KIND_TYPE && signature.Arguments[1].typeSignature.RawType == "varchar" {
+			result.scanType = reflect.TypeOf(NullStringMap{})
+		}
+	case "array":
+		if len(signature.Arguments) != 1 || signature.Arguments[0].Kind != KIND_TYPE {
+			break
+		}
+		elementSignature := signature.Arguments[0].typeSignature
+		switch elementSignature.RawType {
+		case "row", "map":
+			result.element, err = newTypeConverter(elementSignature.RawType, elementSignature)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return result, nil
 }
 
+// setRowValues makes the converter, and the converters of nested ROW fields and array elements,
+// convert the elements of ARRAY(ROW) values to RowValue.
+func (c *typeConverter) setRowValues() {
+	c.rowValues = true
+	for _, field := range c.fields {
+		field.converter.setRowValues()
+	}
+	if c.element != nil {
+		c.element.setRowValues()
+	}
+}
+
 func getNestedTypes(types []string, signature typeSignature) []string {
 	types = append(types, signature.RawType)
 	if len(signature.Arguments) == 1 {
@@ -1511,8 +3925,14 @@ func getScanType(typeNames []string) (reflect.Type, error) {
 	switch typeNames[0] {
 	case "boolean":
 		v = sql.NullBool{}
-	case "json", "char", "varchar", "varbinary", "interval year to month", "interval day to second", "decimal", "ipaddress", "uuid", "unknown":
+	case "json", "char", "varchar", "varbinary", "interval year to month", "interval day to second", "decimal", "unknown":
 		v = sql.NullString{}
+	case "ipaddress":
+		v = NullIP{}
+	case "uuid":
+		v = NullUUID{}
+	case "HyperLogLog", "P4HyperLogLog":
+		v = []byte{}
 	case "tinyint", "smallint":
 		v = sql.NullInt32{}
 	case "integer":
@@ -1521,7 +3941,7 @@ func getScanType(typeNames []string) (reflect.Type, error) {
 		v = sql.NullInt64{}
 	case "real", "double":
 		v = sql.NullFloat64{}
-	case "date", "time", "time with time zone", "timestamp", "timestamp with time zone":
+	case "date", "time", "time with time zone", "timestamp", "timestamp with time zone", "timestamp with local time zone":
 		v = sql.NullTime{}
 	case "map":
 		v = NullMap{}
@@ -1534,11 +3954,13 @@ func getScanType(typeNames []string) (reflect.Type, error) {
 			v = NullSliceBool{}
 		case "json", "char", "varchar", "varbinary", "interval year to month", "interval day to second", "decimal", "ipaddress", "uuid", "unknown":
 			v = NullSliceString{}
-		case "tinyint", "smallint", "integer", "bigint":
+		case "tinyint", "smallint", "integer":
+			v = NullSliceInt32{}
+		case "bigint":
 			v = NullSliceInt64{}
 		case "real", "double":
 			v = NullSliceFloat64{}
-		case "date", "time", "time with time zone", "timestamp", "timestamp with time zone":
+		case "date", "time", "time with time zone", "timestamp", "timestamp with time zone", "timestamp with local time zone":
 			v = NullSliceTime{}
 		case "map":
 			v = NullSliceMap{}
@@ -1551,11 +3973,13 @@ func getScanType(typeNames []string) (reflect.Type, error) {
 				v = NullSlice2Bool{}
 			case "json", "char", "varchar", "varbinary", "interval year to month", "interval day to second", "decimal", "ipaddress", "uuid", "unknown":
 				v = NullSlice2String{}
-			case "tinyint", "smallint", "integer", "bigint":
+			case "tinyint", "smallint", "integer":
+				v = NullSlice2Int32{}
+			case "bigint":
 				v = NullSlice2Int64{}
 			case "real", "double":
 				v = NullSlice2Float64{}
-			case "date", "time", "time with time zone", "timestamp", "timestamp with time zone":
+			case "date", "time", "time with time zone", "timestamp", "timestamp with time zone", "timestamp with local time zone":
 				v = NullSlice2Time{}
 			case "map":
 				v = NullSlice2Map{}
@@ -1568,11 +3992,13 @@ func getScanType(typeNames []string) (reflect.Type, error) {
 					v = NullSlice3Bool{}
 				case "json", "char", "varchar", "varbinary", "interval year to month", "interval day to second", "decimal", "ipaddress", "uuid", "unknown":
 					v = NullSlice3String{}
-				case "tinyint", "smallint", "integer", "bigint":
+				case "tinyint", "smallint", "integer":
+					v = NullSlice3Int32{}
+				case "bigint":
 					v = NullSlice3Int64{}
 				case "real", "double":
 					v = NullSlice3Float64{}
-				case "date", "time", "time with time zone", "timestamp", "timestamp with time zone":
+				case "date", "time", "time with time zone", "timestamp", "timestamp with time zone", "timestamp with local time zone":
 					v = NullSlice3Time{}
 				case "map":
 					v = NullSlice3Map{}
@@ -1602,6 +4028,8 @@ func (c *typeConverter) ConvertValue(v interface{}) (driver.Value, error) {
 			return nil, err
 		}
 		return vv.String, err
+	case "HyperLogLog", "P4HyperLogLog":
+		return scanBase64(v)
 	case "tinyint", "smallint", "integer", "bigint":
 		vv, err := scanNullInt64(v)
 		if !vv.Valid {
@@ -1614,7 +4042,7 @@ func (c *typeConverter) ConvertValue(v interface{}) (driver.Value, error) {
 			return nil, err
 		}
 		return vv.Float64, err
-	case "date", "time", "time with time zone", "timestamp", "timestamp with time zone":
+	case "date", "time", "time with time zone", "timestamp", "timestamp with time zone", "timestamp with local time zone":
 		vv, err := scanNullTime(v)
 		if !vv.Valid {
 			return nil, err
@@ -1629,15 +4057,58 @@ func (c *typeConverter) ConvertValue(v interface{}) (driver.Value, error) {
 		if err := validateSlice(v); err != nil {
 			return nil, err
 		}
-		return v, nil
+		if v == nil || c.element == nil {
+			return v, nil
+		}
+		if c.element.parsedType[0] == "map" {
+			for _, element := range v.([]interface{}) {
+				if err := validateMap(element); err != nil {
+					return nil, err
+				}
+			}
+			return v, nil
+		}
+		if !c.element.rowValues {
+			return v, nil
+		}
+		vs := v.([]interface{})
+		rows := make([]interface{}, len(vs))
+		for i, element := range vs {
+			var err error
+			if rows[i], err = c.element.ConvertValue(element); err != nil {
+				return nil, err
+			}
+		}
+		return rows, nil
 	case "row":
 		if err := validateSlice(v); err != nil {
 			return nil, err
 		}
-		return v, nil
+		if v == nil || !c.hasFieldNames() {
+			return v, nil
+		}
+		return c.convertRow(v.([]interface{}))
 	default:
-		return nil, fmt.Errorf("type not supported: %q", c.typeName)
+		// return values of types without a dedicated conversion as sent by the server
+		vv, err := scanNullString(v)
+		if !vv.Valid {
+			return nil, err
+		}
+		return vv.String, err
+	}
+}
+
+// scanBase64 decodes a binary value, like a HyperLogLog sketch, sent by the server as a base64 string.
+func scanBase64(v interface{}) (driver.Value, error) {
+	vv, err := scanNullString(v)
+	if !vv.Valid {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(vv.String)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %v (%T) to []byte: %w", v, v, err)
 	}
+	return b, nil
 }
 
 func validateMap(v interface{}) error {
@@ -1678,6 +4149,14 @@ type NullSliceBool struct {
 	Valid     bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSliceBool) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceBool, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSliceBool) Scan(value interface{}) error {
 	if value == nil {
@@ -1707,6 +4186,14 @@ type NullSlice2Bool struct {
 	Valid      bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice2Bool) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2Bool, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice2Bool) Scan(value interface{}) error {
 	if value == nil {
@@ -1723,7 +4210,9 @@ func (s *NullSlice2Bool) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.SliceBool
+		if ss.Valid {
+			slice[i] = ss.SliceBool
+		}
 	}
 	s.Slice2Bool = slice
 	s.Valid = true
@@ -1736,6 +4225,14 @@ type NullSlice3Bool struct {
 	Valid      bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice3Bool) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3Bool, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice3Bool) Scan(value interface{}) error {
 	if value == nil {
@@ -1752,7 +4249,9 @@ func (s *NullSlice3Bool) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.Slice2Bool
+		if ss.Valid {
+			slice[i] = ss.Slice2Bool
+		}
 	}
 	s.Slice3Bool = slice
 	s.Valid = true
@@ -1777,6 +4276,14 @@ type NullSliceString struct {
 	Valid       bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSliceString) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceString, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSliceString) Scan(value interface{}) error {
 	if value == nil {
@@ -1806,6 +4313,14 @@ type NullSlice2String struct {
 	Valid        bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice2String) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2String, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice2String) Scan(value interface{}) error {
 	if value == nil {
@@ -1822,7 +4337,9 @@ func (s *NullSlice2String) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.SliceString
+		if ss.Valid {
+			slice[i] = ss.SliceString
+		}
 	}
 	s.Slice2String = slice
 	s.Valid = true
@@ -1835,6 +4352,14 @@ type NullSlice3String struct {
 	Valid        bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice3String) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3String, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice3String) Scan(value interface{}) error {
 	if value == nil {
@@ -1851,7 +4376,9 @@ func (s *NullSlice3String) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.Slice2String
+		if ss.Valid {
+			slice[i] = ss.Slice2String
+		}
 	}
 	s.Slice3String = slice
 	s.Valid = true
@@ -1881,6 +4408,14 @@ type NullSliceInt64 struct {
 	Valid      bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSliceInt64) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceInt64, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSliceInt64) Scan(value interface{}) error {
 	if value == nil {
@@ -1910,6 +4445,14 @@ type NullSlice2Int64 struct {
 	Valid       bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice2Int64) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2Int64, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice2Int64) Scan(value interface{}) error {
 	if value == nil {
@@ -1926,7 +4469,9 @@ func (s *NullSlice2Int64) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.SliceInt64
+		if ss.Valid {
+			slice[i] = ss.SliceInt64
+		}
 	}
 	s.Slice2Int64 = slice
 	s.Valid = true
@@ -1939,6 +4484,14 @@ type NullSlice3Int64 struct {
 	Valid       bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice3Int64) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3Int64, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice3Int64) Scan(value interface{}) error {
 	if value == nil {
@@ -1955,13 +4508,141 @@ func (s *NullSlice3Int64) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.Slice2Int64
+		if ss.Valid {
+			slice[i] = ss.Slice2Int64
+		}
 	}
 	s.Slice3Int64 = slice
 	s.Valid = true
 	return nil
 }
 
+func scanNullInt32(v interface{}) (sql.NullInt32, error) {
+	vv, err := scanNullInt64(v)
+	if err != nil || !vv.Valid {
+		return sql.NullInt32{}, err
+	}
+	if vv.Int64 < math.MinInt32 || vv.Int64 > math.MaxInt32 {
+		return sql.NullInt32{}, fmt.Errorf("cannot convert %v (%T) to int32: value out of range", v, v)
+	}
+	return sql.NullInt32{Valid: true, Int32: int32(vv.Int64)}, nil
+}
+
+// NullSliceInt32 represents a slice of int32 that may be null.
+type NullSliceInt32 struct {
+	SliceInt32 []sql.NullInt32
+	Valid      bool
+}
+
+// Value implements the driver.Valuer interface.
+func (s NullSliceInt32) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceInt32, nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *NullSliceInt32) Scan(value interface{}) error {
+	if value == nil {
+		s.SliceInt32, s.Valid = []sql.NullInt32{}, false
+		return nil
+	}
+	vs, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to []int32", value, value)
+	}
+	slice := make([]sql.NullInt32, len(vs))
+	for i := range vs {
+		v, err := scanNullInt32(vs[i])
+		if err != nil {
+			return err
+		}
+		slice[i] = v
+	}
+	s.SliceInt32 = slice
+	s.Valid = true
+	return nil
+}
+
+// NullSlice2Int32 represents a two-dimensional slice of int32 that may be null.
+type NullSlice2Int32 struct {
+	Slice2Int32 [][]sql.NullInt32
+	Valid       bool
+}
+
+// Value implements the driver.Valuer interface.
+func (s NullSlice2Int32) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2Int32, nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *NullSlice2Int32) Scan(value interface{}) error {
+	if value == nil {
+		s.Slice2Int32, s.Valid = [][]sql.NullInt32{}, false
+		return nil
+	}
+	vs, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to [][]int32", value, value)
+	}
+	slice := make([][]sql.NullInt32, len(vs))
+	for i := range vs {
+		var ss NullSliceInt32
+		if err := ss.Scan(vs[i]); err != nil {
+			return err
+		}
+		if ss.Valid {
+			slice[i] = ss.SliceInt32
+		}
+	}
+	s.Slice2Int32 = slice
+	s.Valid = true
+	return nil
+}
+
+// NullSlice3Int32 implements a three-dimensional slice of int32 that may be null.
+type NullSlice3Int32 struct {
+	Slice3Int32 [][][]sql.NullInt32
+	Valid       bool
+}
+
+// Value implements the driver.Valuer interface.
+func (s NullSlice3Int32) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3Int32, nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *NullSlice3Int32) Scan(value interface{}) error {
+	if value == nil {
+		s.Slice3Int32, s.Valid = [][][]sql.NullInt32{}, false
+		return nil
+	}
+	vs, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to [][][]int32", value, value)
+	}
+	slice := make([][][]sql.NullInt32, len(vs))
+	for i := range vs {
+		var ss NullSlice2Int32
+		if err := ss.Scan(vs[i]); err != nil {
+			return err
+		}
+		if ss.Valid {
+			slice[i] = ss.Slice2Int32
+		}
+	}
+	s.Slice3Int32 = slice
+	s.Valid = true
+	return nil
+}
+
 func scanNullFloat64(v interface{}) (sql.NullFloat64, error) {
 	if v == nil {
 		return sql.NullFloat64{}, nil
@@ -2000,6 +4681,14 @@ type NullSliceFloat64 struct {
 	Valid        bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSliceFloat64) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceFloat64, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSliceFloat64) Scan(value interface{}) error {
 	if value == nil {
@@ -2029,6 +4718,14 @@ type NullSlice2Float64 struct {
 	Valid         bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice2Float64) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2Float64, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice2Float64) Scan(value interface{}) error {
 	if value == nil {
@@ -2045,7 +4742,9 @@ func (s *NullSlice2Float64) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.SliceFloat64
+		if ss.Valid {
+			slice[i] = ss.SliceFloat64
+		}
 	}
 	s.Slice2Float64 = slice
 	s.Valid = true
@@ -2058,6 +4757,14 @@ type NullSlice3Float64 struct {
 	Valid         bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice3Float64) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3Float64, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice3Float64) Scan(value interface{}) error {
 	if value == nil {
@@ -2074,7 +4781,9 @@ func (s *NullSlice3Float64) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.Slice2Float64
+		if ss.Valid {
+			slice[i] = ss.Slice2Float64
+		}
 	}
 	s.Slice3Float64 = slice
 	s.Valid = true
@@ -2154,52 +4863,205 @@ func parseNullTimeWithLocation(v string) (NullTime, error) {
 				return NullTime{Valid: true, Time: t}, nil
 			}
 		}
-		return NullTime{}, err
+		return NullTime{}, err
+	}
+	loc, err := time.LoadLocation(location)
+	// Not a named location.
+	if err != nil {
+		return NullTime{}, fmt.Errorf("cannot load timezone %q: %v", location, err)
+	}
+
+	for _, layout := range timeLayouts {
+		t, err = time.ParseInLocation(layout, stamp, loc)
+		if err == nil {
+			return NullTime{Valid: true, Time: t}, nil
+		}
+	}
+	return NullTime{}, err
+}
+
+// NullTime represents a time.Time value that can be null.
+// The NullTime supports Trino's Date, Time and Timestamp data types,
+// with or without time zone.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Value implements the driver.Valuer interface.
+func (s NullTime) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Time, nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *NullTime) Scan(value interface{}) error {
+	if value == nil {
+		s.Time, s.Valid = time.Time{}, false
+		return nil
+	}
+	switch t := value.(type) {
+	case time.Time:
+		s.Time, s.Valid = t, true
+	case NullTime:
+		*s = t
+	}
+	return nil
+}
+
+// NullDuration represents a time.Duration value that can be null.
+// The NullDuration supports Trino's INTERVAL DAY TO SECOND data type,
+// but not INTERVAL YEAR TO MONTH, since months have no fixed duration.
+type NullDuration struct {
+	Duration time.Duration
+	Valid    bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (d *NullDuration) Scan(value interface{}) error {
+	if value == nil {
+		d.Duration, d.Valid = 0, false
+		return nil
+	}
+	switch v := value.(type) {
+	case string:
+		duration, err := parseDayToSecondInterval(v)
+		if err != nil {
+			return err
+		}
+		d.Duration, d.Valid = duration, true
+	case time.Duration:
+		d.Duration, d.Valid = v, true
+	default:
+		return fmt.Errorf("trino: cannot convert %v (%T) to time.Duration", value, value)
 	}
-	loc, err := time.LoadLocation(location)
-	// Not a named location.
+	return nil
+}
+
+// parseDayToSecondInterval parses an interval day to second, formatted as days hh:mm:ss.fff.
+func parseDayToSecondInterval(v string) (time.Duration, error) {
+	s, negative := strings.CutPrefix(v, "-")
+	days, clock, ok := strings.Cut(s, " ")
+	parts := strings.Split(clock, ":")
+	if !ok || len(parts) != 3 {
+		if strings.Contains(s, "-") {
+			// months have no fixed duration
+			return 0, fmt.Errorf("trino: cannot convert interval year to month %q to time.Duration", v)
+		}
+		return 0, fmt.Errorf("trino: cannot convert interval %q to time.Duration", v)
+	}
+	d, err := strconv.ParseInt(days, 10, 64)
+	if err != nil || d > int64(math.MaxInt64/(24*time.Hour)) {
+		return 0, fmt.Errorf("trino: cannot convert interval %q to time.Duration", v)
+	}
+	h, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		return NullTime{}, fmt.Errorf("cannot load timezone %q: %v", location, err)
+		return 0, fmt.Errorf("trino: cannot convert interval %q to time.Duration", v)
+	}
+	m, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("trino: cannot convert interval %q to time.Duration", v)
+	}
+	seconds, err := time.ParseDuration(parts[2] + "s")
+	if err != nil {
+		return 0, fmt.Errorf("trino: cannot convert interval %q to time.Duration", v)
+	}
+	duration := time.Duration(d)*24*time.Hour + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + seconds
+	if duration < 0 {
+		return 0, fmt.Errorf("trino: interval %q is out of range for time.Duration", v)
 	}
+	if negative {
+		duration = -duration
+	}
+	return duration, nil
+}
 
-	for _, layout := range timeLayouts {
-		t, err = time.ParseInLocation(layout, stamp, loc)
-		if err == nil {
-			return NullTime{Valid: true, Time: t}, nil
+// NullIP represents a net.IP value that can be null.
+// The NullIP supports Trino's IPADDRESS data type.
+type NullIP struct {
+	IP    net.IP
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (i *NullIP) Scan(value interface{}) error {
+	if value == nil {
+		i.IP, i.Valid = nil, false
+		return nil
+	}
+	switch v := value.(type) {
+	case string:
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return fmt.Errorf("trino: cannot convert %q to net.IP", v)
 		}
+		i.IP, i.Valid = ip, true
+	case net.IP:
+		i.IP, i.Valid = v, true
+	default:
+		return fmt.Errorf("trino: cannot convert %v (%T) to net.IP", value, value)
 	}
-	return NullTime{}, err
+	return nil
 }
 
-// NullTime represents a time.Time value that can be null.
-// The NullTime supports Trino's Date, Time and Timestamp data types,
-// with or without time zone.
-type NullTime struct {
-	Time  time.Time
+// NullUUID represents a UUID value that can be null.
+// The NullUUID supports Trino's UUID data type.
+type NullUUID struct {
+	UUID  [16]byte
 	Valid bool
 }
 
 // Scan implements the sql.Scanner interface.
-func (s *NullTime) Scan(value interface{}) error {
+func (u *NullUUID) Scan(value interface{}) error {
 	if value == nil {
-		s.Time, s.Valid = time.Time{}, false
+		u.UUID, u.Valid = [16]byte{}, false
 		return nil
 	}
-	switch t := value.(type) {
-	case time.Time:
-		s.Time, s.Valid = t, true
-	case NullTime:
-		*s = t
+	switch v := value.(type) {
+	case string:
+		uuid, err := parseUUID(v)
+		if err != nil {
+			return err
+		}
+		u.UUID, u.Valid = uuid, true
+	case [16]byte:
+		u.UUID, u.Valid = v, true
+	default:
+		return fmt.Errorf("trino: cannot convert %v (%T) to UUID", value, value)
 	}
 	return nil
 }
 
+// parseUUID parses a UUID in the standard xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx format.
+func parseUUID(v string) ([16]byte, error) {
+	var uuid [16]byte
+	if len(v) != 36 || v[8] != '-' || v[13] != '-' || v[18] != '-' || v[23] != '-' {
+		return uuid, fmt.Errorf("trino: cannot convert %q to UUID", v)
+	}
+	digits := v[0:8] + v[9:13] + v[14:18] + v[19:23] + v[24:36]
+	if _, err := hex.Decode(uuid[:], []byte(digits)); err != nil {
+		return uuid, fmt.Errorf("trino: cannot convert %q to UUID: %w", v, err)
+	}
+	return uuid, nil
+}
+
 // NullSliceTime represents a slice of time.Time that may be null.
+// NULL elements of the array are scanned as NullTime values that are not valid.
 type NullSliceTime struct {
 	SliceTime []NullTime
 	Valid     bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSliceTime) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceTime, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSliceTime) Scan(value interface{}) error {
 	if value == nil {
@@ -2224,11 +5086,20 @@ func (s *NullSliceTime) Scan(value interface{}) error {
 }
 
 // NullSlice2Time represents a two-dimensional slice of time.Time that may be null.
+// NULL elements of the array are scanned as NullTime values that are not valid.
 type NullSlice2Time struct {
 	Slice2Time [][]NullTime
 	Valid      bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice2Time) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2Time, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice2Time) Scan(value interface{}) error {
 	if value == nil {
@@ -2245,7 +5116,9 @@ func (s *NullSlice2Time) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.SliceTime
+		if ss.Valid {
+			slice[i] = ss.SliceTime
+		}
 	}
 	s.Slice2Time = slice
 	s.Valid = true
@@ -2253,11 +5126,20 @@ func (s *NullSlice2Time) Scan(value interface{}) error {
 }
 
 // NullSlice3Time represents a three-dimensional slice of time.Time that may be null.
+// NULL elements of the array are scanned as NullTime values that are not valid.
 type NullSlice3Time struct {
 	Slice3Time [][][]NullTime
 	Valid      bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice3Time) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3Time, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice3Time) Scan(value interface{}) error {
 	if value == nil {
@@ -2274,7 +5156,9 @@ func (s *NullSlice3Time) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.Slice2Time
+		if ss.Valid {
+			slice[i] = ss.Slice2Time
+		}
 	}
 	s.Slice3Time = slice
 	s.Valid = true
@@ -2287,6 +5171,24 @@ type NullMap struct {
 	Valid bool
 }
 
+// Value implements the driver.Valuer interface.
+// The map is passed to queries as a Trino MAP with its keys sorted.
+func (m NullMap) Value() (driver.Value, error) {
+	if !m.Valid {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(m.Map))
+	for k := range m.Map {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		values[i] = m.Map[k]
+	}
+	return TrinoMap(keys, values), nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (m *NullMap) Scan(v interface{}) error {
 	if v == nil {
@@ -2297,12 +5199,67 @@ func (m *NullMap) Scan(v interface{}) error {
 	return nil
 }
 
+// NullStringMap represents a map of strings that may be null, like a MAP(VARCHAR, VARCHAR) column.
+// Scanning a map with values that are not strings, including NULL values, returns an error.
+type NullStringMap struct {
+	Map   map[string]string
+	Valid bool
+}
+
+// Value implements the driver.Valuer interface.
+// The map is passed to queries as a Trino MAP with its keys sorted.
+func (m NullStringMap) Value() (driver.Value, error) {
+	if !m.Valid {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(m.Map))
+	for k := range m.Map {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = m.Map[k]
+	}
+	return TrinoMap(keys, values), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (m *NullStringMap) Scan(v interface{}) error {
+	if v == nil {
+		m.Map, m.Valid = map[string]string{}, false
+		return nil
+	}
+	vm, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to map[string]string", v, v)
+	}
+	result := make(map[string]string, len(vm))
+	for key, value := range vm {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("trino: cannot convert value %v (%T) of map key %q to string", value, value, key)
+		}
+		result[key] = s
+	}
+	m.Map, m.Valid = result, true
+	return nil
+}
+
 // NullSliceMap represents a slice of NullMap that may be null.
 type NullSliceMap struct {
 	SliceMap []NullMap
 	Valid    bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSliceMap) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.SliceMap, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSliceMap) Scan(value interface{}) error {
 	if value == nil {
@@ -2334,6 +5291,14 @@ type NullSlice2Map struct {
 	Valid     bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice2Map) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice2Map, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice2Map) Scan(value interface{}) error {
 	if value == nil {
@@ -2350,7 +5315,9 @@ func (s *NullSlice2Map) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.SliceMap
+		if ss.Valid {
+			slice[i] = ss.SliceMap
+		}
 	}
 	s.Slice2Map = slice
 	s.Valid = true
@@ -2363,6 +5330,14 @@ type NullSlice3Map struct {
 	Valid     bool
 }
 
+// Value implements the driver.Valuer interface.
+func (s NullSlice3Map) Value() (driver.Value, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	return s.Slice3Map, nil
+}
+
 // Scan implements the sql.Scanner interface.
 func (s *NullSlice3Map) Scan(value interface{}) error {
 	if value == nil {
@@ -2379,14 +5354,395 @@ func (s *NullSlice3Map) Scan(value interface{}) error {
 		if err := ss.Scan(vs[i]); err != nil {
 			return err
 		}
-		slice[i] = ss.Slice2Map
+		if ss.Valid {
+			slice[i] = ss.Slice2Map
+		}
 	}
 	s.Slice3Map = slice
 	s.Valid = true
 	return nil
 }
 
+// NullTypedMap represents a map type that may be null, with keys of type K and values of type V.
+// Keys can be strings, booleans or numbers. Values can be any type supported by
+// the driver, or implement the sql.Scanner interface.
+type NullTypedMap[K comparable, V any] struct {
+	Map   map[K]V
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (m *NullTypedMap[K, V]) Scan(v interface{}) error {
+	if v == nil {
+		m.Map, m.Valid = map[K]V{}, false
+		return nil
+	}
+	vm, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to map[%T]%T", v, v, *new(K), *new(V))
+	}
+	result := make(map[K]V, len(vm))
+	for key, value := range vm {
+		k, err := scanMapKey[K](key)
+		if err != nil {
+			return err
+		}
+		result[k], err = scanMapValue[V](value)
+		if err != nil {
+			return err
+		}
+	}
+	m.Map, m.Valid = result, true
+	return nil
+}
+
+func scanMapKey[K comparable](key string) (K, error) {
+	var result K
+	v := reflect.ValueOf(&result).Elem()
+	var err error
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(key)
+	case v.Kind() == reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(key)
+		v.SetBool(b)
+	case v.CanInt():
+		var i int64
+		i, err = strconv.ParseInt(key, 10, v.Type().Bits())
+		v.SetInt(i)
+	case v.CanUint():
+		var u uint64
+		u, err = strconv.ParseUint(key, 10, v.Type().Bits())
+		v.SetUint(u)
+	case v.CanFloat():
+		var f float64
+		f, err = strconv.ParseFloat(key, v.Type().Bits())
+		v.SetFloat(f)
+	default:
+		return result, fmt.Errorf("trino: unsupported map key type %T", result)
+	}
+	if err != nil {
+		return result, fmt.Errorf("trino: cannot convert map key %q to %T: %w", key, result, err)
+	}
+	return result, nil
+}
+
+func scanMapValue[V any](value interface{}) (V, error) {
+	var result V
+	var err error
+	switch p := any(&result).(type) {
+	case sql.Scanner:
+		err = p.Scan(value)
+	case *string:
+		var vv sql.NullString
+		vv, err = scanNullString(value)
+		*p = vv.String
+	case *bool:
+		var vv sql.NullBool
+		vv, err = scanNullBool(value)
+		*p = vv.Bool
+	case *int64:
+		var vv sql.NullInt64
+		vv, err = scanNullInt64(value)
+		*p = vv.Int64
+	case *float64:
+		var vv sql.NullFloat64
+		vv, err = scanNullFloat64(value)
+		*p = vv.Float64
+	case *time.Time:
+		var vv NullTime
+		vv, err = scanNullTime(value)
+		*p = vv.Time
+	default:
+		if value == nil {
+			break
+		}
+		vv, ok := value.(V)
+		if !ok {
+			err = fmt.Errorf("cannot convert %v (%T) to %T", value, value, result)
+		}
+		result = vv
+	}
+	if err != nil {
+		return result, fmt.Errorf("trino: %w", err)
+	}
+	return result, nil
+}
+
+// RowValue is the value of a named ROW, with the names of its fields from the column type. Elements
+// of ARRAY(ROW) values are only returned as RowValue by connections with the rowValues parameter set.
+// Other rows, and the values of anonymous rows, are []interface{} slices of the raw values of their fields.
+type RowValue struct {
+	// Names of the fields, in order, empty for fields of anonymous rows.
+	Names []string
+	// Values of the fields, in order, converted like the values of columns of the same types.
+	Values []interface{}
+}
+
+// hasFieldNames returns true if the fields of a ROW type have names.
+func (c *typeConverter) hasFieldNames() bool {
+	for _, field := range c.fields {
+		if field.name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// convertRow converts the fields of a ROW value with their converters.
+func (c *typeConverter) convertRow(v []interface{}) (RowValue, error) {
+	if len(v) != len(c.fields) {
+		return RowValue{}, fmt.Errorf("cannot convert %v (%T) to %s", v, v, c.typeName)
+	}
+	row := RowValue{Names: make([]string, len(v)), Values: make([]interface{}, len(v))}
+	for i, field := range c.fields {
+		var err error
+		row.Names[i] = field.name
+		row.Values[i], err = field.converter.ConvertValue(v[i])
+		if err != nil {
+			return RowValue{}, err
+		}
+	}
+	return row, nil
+}
+
+// NullRow represents a ROW value that may be null, scanned into a struct of type T.
+//
+// Fields of named rows are matched to the exported fields of T by name, ignoring case, or by
+// the name set in a `trino:"field_name"` struct tag, and a named field without a matching
+// struct field is an error. Fields of anonymous rows, and of rows in arrays without the
+// rowValues parameter, are returned as []interface{} slices and matched to the exported
+// fields of T by position, so T cannot have field name tags. Struct fields tagged with
+// `trino:"-"` are ignored.
+type NullRow[T any] struct {
+	Row   T
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (r *NullRow[T]) Scan(value interface{}) error {
+	var row T
+	if value == nil {
+		r.Row, r.Valid = row, false
+		return nil
+	}
+	if err := scanRow(reflect.ValueOf(&row).Elem(), value); err != nil {
+		return err
+	}
+	r.Row, r.Valid = row, true
+	return nil
+}
+
+// NullSliceRow represents an ARRAY(ROW) value that may be null, with each row scanned
+// into a struct of type T like with NullRow.
+type NullSliceRow[T any] struct {
+	SliceRow []NullRow[T]
+	Valid    bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *NullSliceRow[T]) Scan(value interface{}) error {
+	if value == nil {
+		s.SliceRow, s.Valid = []NullRow[T]{}, false
+		return nil
+	}
+	vs, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to []NullRow[%T]", value, value, *new(T))
+	}
+	slice := make([]NullRow[T], len(vs))
+	for i := range vs {
+		if err := slice[i].Scan(vs[i]); err != nil {
+			return err
+		}
+	}
+	s.SliceRow = slice
+	s.Valid = true
+	return nil
+}
+
+// NullSliceRowMap represents an ARRAY(ROW) value of named rows that may be null, with each
+// row stored as a map keyed by field name. It requires the rows to be returned as RowValue,
+// by connections with the rowValues parameter set.
+type NullSliceRowMap struct {
+	SliceRowMap []NullMap
+	Valid       bool
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *NullSliceRowMap) Scan(value interface{}) error {
+	if value == nil {
+		s.SliceRowMap, s.Valid = []NullMap{}, false
+		return nil
+	}
+	vs, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("trino: cannot convert %v (%T) to []NullMap", value, value)
+	}
+	slice := make([]NullMap, len(vs))
+	for i, v := range vs {
+		if v == nil {
+			slice[i] = NullMap{Map: map[string]interface{}{}}
+			continue
+		}
+		row, ok := v.(RowValue)
+		if !ok {
+			return fmt.Errorf("trino: cannot convert %v (%T) to a map, rows must be returned as RowValue with the %s parameter", v, v, rowValuesConfig)
+		}
+		m := make(map[string]interface{}, len(row.Values))
+		for j, name := range row.Names {
+			if name == "" {
+				return fmt.Errorf("trino: cannot convert field %d of an anonymous row to a map entry", j)
+			}
+			m[name] = row.Values[j]
+		}
+		slice[i] = NullMap{Map: m, Valid: true}
+	}
+	s.SliceRowMap = slice
+	s.Valid = true
+	return nil
+}
+
+func scanRow(dest reflect.Value, value interface{}) error {
+	if dest.Kind() != reflect.Struct {
+		return fmt.Errorf("trino: cannot scan a row into %s, a struct is required", dest.Type())
+	}
+	var names []string
+	var vs []interface{}
+	switch row := value.(type) {
+	case RowValue:
+		names, vs = row.Names, row.Values
+	case []interface{}:
+		if name, ok := rowNameTag(dest.Type()); ok {
+			return fmt.Errorf("trino: cannot scan a row without field names into %s with field name tag %q, set the %s parameter for rows in arrays", dest.Type(), name, rowValuesConfig)
+		}
+		vs = row
+	default:
+		return fmt.Errorf("trino: cannot convert %v (%T) to %s", value, value, dest.Type())
+	}
+	for i, v := range vs {
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+		field, ok := rowStructField(dest, name, i)
+		if !ok {
+			if name != "" {
+				return fmt.Errorf("trino: cannot scan row field %q into %s, no struct field matches its name", name, dest.Type())
+			}
+			continue
+		}
+		if err := assignRowField(dest.FieldByIndex(field.Index), v); err != nil {
+			return fmt.Errorf("trino: cannot scan row field %d into %s.%s: %w", i, dest.Type(), field.Name, err)
+		}
+	}
+	return nil
+}
+
+// rowNameTag returns the first row field name set in a `trino` tag of the fields of struct type t.
+func rowNameTag(t reflect.Type) (string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("trino"); tag != "" && tag != "-" {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// rowStructField finds the struct field matching a row field by name,
+// or by position if the row field has no name.
+func rowStructField(dest reflect.Value, name string, position int) (reflect.StructField, bool) {
+	t := dest.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("trino")
+		if tag == "-" {
+			continue
+		}
+		if name == "" {
+			if position == 0 {
+				return field, true
+			}
+			position--
+			continue
+		}
+		if tag == name || tag == "" && strings.EqualFold(field.Name, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func assignRowField(dest reflect.Value, value interface{}) error {
+	if scanner, ok := dest.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+	if value == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+	if dest.Kind() == reflect.Pointer {
+		v := reflect.New(dest.Type().Elem())
+		if err := assignRowField(v.Elem(), value); err != nil {
+			return err
+		}
+		dest.Set(v)
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(dest.Type()):
+		dest.Set(v)
+	case dest.Kind() == reflect.Struct && (v.Kind() == reflect.Slice || v.Type() == reflect.TypeOf(RowValue{})):
+		return scanRow(dest, value)
+	case v.Type() == reflect.TypeOf(json.Number("")) && (dest.CanInt() || dest.CanUint() || dest.CanFloat()):
+		// raw numbers of rows returned as []interface{} slices
+		return assignRowNumber(dest, value.(json.Number))
+	case v.CanInt() && dest.CanInt() && !dest.OverflowInt(v.Int()),
+		v.CanInt() && dest.CanUint() && v.Int() >= 0 && !dest.OverflowUint(uint64(v.Int())),
+		v.CanFloat() && dest.CanFloat(),
+		v.Kind() == reflect.String && dest.Kind() == reflect.String:
+		dest.Set(v.Convert(dest.Type()))
+	default:
+		return fmt.Errorf("cannot convert %v (%T) to %s", value, value, dest.Type())
+	}
+	return nil
+}
+
+// assignRowNumber parses a raw number of a ROW field into an integer or floating point field.
+func assignRowNumber(dest reflect.Value, n json.Number) error {
+	switch {
+	case dest.CanInt():
+		i, err := strconv.ParseInt(n.String(), 10, dest.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dest.SetInt(i)
+	case dest.CanUint():
+		u, err := strconv.ParseUint(n.String(), 10, dest.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dest.SetUint(u)
+	default:
+		f, err := strconv.ParseFloat(n.String(), dest.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dest.SetFloat(f)
+	}
+	return nil
+}
+
+// QueryProgressInfo is passed to progress callbacks with the latest statistics of a query.
 type QueryProgressInfo struct {
+	// QueryID is the ID of the query in Trino, to tell updates of concurrent queries apart.
+	QueryID string
+	// Deprecated: Use QueryID instead.
 	QueryId    string
 	QueryStats stmtStats
 }
@@ -17,21 +17,36 @@ package trino
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"log/slog"
 	"math"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
 	"runtime/debug"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jcmturner/gokrb5/v8/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func TestConfig(t *testing.T) {
@@ -48,684 +63,2348 @@ func TestConfig(t *testing.T) {
 	assert.Equal(t, want, dsn)
 }
 
-func TestConfigSSLCertPath(t *testing.T) {
+func TestConfigSource(t *testing.T) {
 	c := &Config{
-		ServerURI:         "https://foobar@localhost:8080",
-		SessionProperties: map[string]string{"query_priority": "1"},
-		SSLCertPath:       "cert.pem",
+		ServerURI: "http://foobar@localhost:8080",
+		Source:    "my-service",
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	want := "https://foobar@localhost:8080?SSLCertPath=cert.pem&session_properties=query_priority%3A1&source=trino-go-client"
-
+	want := "http://foobar@localhost:8080?source=my-service"
 	assert.Equal(t, want, dsn)
-}
-
-func TestConfigSSLCert(t *testing.T) {
-	sslCert := `-----BEGIN CERTIFICATE-----
-MIIFijCCA3ICCQDngXKCZFwSazANBgkqhkiG9w0BAQsFADCBhjELMAkGA1UEBhMC
-WFgxEjAQBgNVBAgMCVN0YXRlTmFtZTERMA8GA1UEBwwIQ2l0eU5hbWUxFDASBgNV
-BAoMC0NvbXBhbnlOYW1lMRswGQYDVQQLDBJDb21wYW55U2VjdGlvbk5hbWUxHTAb
-BgNVBAMMFENvbW1vbk5hbWVPckhvc3RuYW1lMB4XDTIzMDUxNzE2MzQ0MloXDTMz
-MDUxNDE2MzQ0MlowgYYxCzAJBgNVBAYTAlhYMRIwEAYDVQQIDAlTdGF0ZU5hbWUx
-ETAPBgNVBAcMCENpdHlOYW1lMRQwEgYDVQQKDAtDb21wYW55TmFtZTEbMBkGA1UE
-CwwSQ29tcGFueVNlY3Rpb25OYW1lMR0wGwYDVQQDDBRDb21tb25OYW1lT3JIb3N0
-bmFtZTCCAiIwDQYJKoZIhvcNAQEBBQADggIPADCCAgoCggIBAKzz/SIuOiHZbUAH
-xCWrMaiJybdHHHl0smCu50XKvl/ZkszO1c4aES8/Vohw44ttaE+GOknTSGPka356
-NqwdPYMjnXN0d5HY5T5nOfgLxGD/1iCHACrT4gkd1asJ7eFaUgud0a+e9+oG53Vh
-Z3QV8+5JaWPuBMudJ8EOtrPMd0dJKVzeExTbpQLJ9HdIsHc6DXqshACd8Iy+ezqf
-OoYMYyJMAHO86MZrTs3t9AwUADlvntrwwObVrZ3v43IOKwJTRnpImmVlkouKrGn/
-HKzRmJEJ6hJQXhuhqI/0rr61XR8aa8Gs0FqtTTMJ32+PciPPzFtFVLAeA417lYz+
-uXZ6IpTLK4oDH8Q6gJY80GYqcGc+01ZY90W2L+odTz9P74vnTvsUgSjOcy7prJ0+
-WxoeBNPvkLeetX9WDZW4XaR++HVO1qelNJQqeB6Nver9MJdKkXvR3OxT6iluqXfA
-l9JJ57tnzspSrttjWG4kwwiaGn/4xPqd95Hp0r1WAK8U0Cqtvz+Zw9jl341tC1Ya
-K1KFIErZYf0KX8ZiYvmkHaTRxYiCmFnnfLtGdrAWkacisLKMhjeb9LXwC/TVtvio
-a+ofiW2DX80pQptkfNJs9P19ZFEojPAEFHiZFpz5yZSxHglxIsdIhRsuy5xb/KTo
-zey3tsKQJaFIah+aHKjyn3uZx2IRAgMBAAEwDQYJKoZIhvcNAQELBQADggIBAIs5
-sbCMB6bT0hcNFqFRCI/BL23m5jwdL9kNWDlEQxBvErtzTC+uStGrCqwV+qu49QAZ
-64kUolbzFyq/hQFpHd+9EzNkZGbiOf5toWaBUP6jaZzqYPdfDW+AwIA7iPHcqwH1
-iWX2zuAWAICy4H+S4oa/ShOPc8BrrnS8k5f1NpergOhd+wl+szuXJN9Tjli3wd/k
-L7f86xvZfOrEbss8YP4QE0+mKh6G71NLEVQ4SV7yIE2hCNLDFWS2ltGVRLv6CDaQ
-fXIQrZx2Khvpj+HI/hrwm1wV8Cg5w2IvB831YjTSepSoos0Cc/qYC78zqol/NbwL
-7TdHtuZKukDrisRiCDdoKFmS1/IUVeVR2352CG8G3Zo0wwfzoKLxLUtunnrKMmmO
-r2jXykqP2hb1dApBNFM7FoaJ7a0j6EcURW8wYl4I+b9ymftPnnZ8mgrjwvLh5ETj
-RgGsIBychLZoc1WWTZWu62+mvmSJnzEIFfaiSeYZLaL6qFHm6kqsAUn4s1Looj8/
-XoCNjMecchWbpHGCPwMFH1k2smxu7bKk/RJNuWSVn1IPUceJnOBHZGj92aJGZpjr
-8j39T3dK9F2r5rHwjZpeEIhyhbLw6pYKif+lBgAWJD3waG0ycwURA02/POHN4CpT
-FKu5ZAlRfb2aYegr49DHhzoVAdInWQmP+5EZEUD1
------END CERTIFICATE-----`
-	c := &Config{
-		ServerURI:         "https://foobar@localhost:8080",
-		SessionProperties: map[string]string{"query_priority": "1"},
-		SSLCert:           sslCert,
-	}
 
-	dsn, err := c.FormatDSN()
+	conn, err := newConn(dsn)
 	require.NoError(t, err)
+	assert.Equal(t, "my-service", conn.httpHeaders.Get(trinoSourceHeader))
 
-	want := "https://foobar@localhost:8080?SSLCert=" + url.QueryEscape(sslCert) + "&session_properties=query_priority%3A1&source=trino-go-client"
+	c.Source = ""
+	dsn, err = c.FormatDSN()
+	require.NoError(t, err)
 
-	assert.Equal(t, want, dsn)
+	conn, err = newConn(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "trino-go-client", conn.httpHeaders.Get(trinoSourceHeader))
 }
 
-func TestExtraCredentials(t *testing.T) {
+func TestConfigCompressionDisabled(t *testing.T) {
 	c := &Config{
-		ServerURI:        "http://foobar@localhost:8080",
-		ExtraCredentials: map[string]string{"token": "mYtOkEn", "otherToken": "oThErToKeN%*!#@special"},
+		ServerURI:           "http://foobar@localhost:8080",
+		CompressionDisabled: true,
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	want := "http://foobar@localhost:8080?extra_credentials=otherToken%3AoThErToKeN%25%2A%21%23%40special%3Btoken%3AmYtOkEn&source=trino-go-client"
+	want := "http://foobar@localhost:8080?compressionDisabled=true&source=trino-go-client"
 	assert.Equal(t, want, dsn)
-}
 
-func TestInvalidExtraCredentials(t *testing.T) {
-	testcases := []struct {
-		Name        string
-		Credentials map[string]string
-		Error       string
-	}{
-		{
-			Name:        "Empty key",
-			Credentials: map[string]string{"": "emptyKey"},
-			Error:       "trino: extra_credentials key is empty",
-		},
-		{
-			Name:        "Empty value",
-			Credentials: map[string]string{"valid": "a", "emptyValue": ""},
-			Error:       "trino: extra_credentials value is empty",
-		},
-		{
-			Name:        "Unprintable key",
-			Credentials: map[string]string{"😊": "unprintableKey"},
-			Error:       "trino: extra_credentials key '😊' contains spaces or is not printable ASCII",
-		},
-		{
-			Name:        "Unprintable value",
-			Credentials: map[string]string{"unprintableValue": "😊"},
-			Error:       "trino: extra_credentials value for key 'unprintableValue' contains spaces or is not printable ASCII",
-		},
-	}
+	var acceptEncoding []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = append(acceptEncoding, r.Header.Get("Accept-Encoding"))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
 
-	for _, tc := range testcases {
+	t.Cleanup(ts.Close)
 
-		t.Run(tc.Name, func(t *testing.T) {
-			c := &Config{
-				ServerURI:        "http://foobar@localhost:8080",
-				ExtraCredentials: tc.Credentials,
-			}
-			dsn, err := c.FormatDSN()
-			require.NoError(t, err)
-			db, err := sql.Open("trino", dsn)
-			require.NoError(t, err)
-			err = db.Ping()
-			assert.EqualError(t, err, tc.Error)
-		})
+	for _, dsn := range []string{ts.URL, ts.URL + "?compressionDisabled=true"} {
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+		assert.NoError(t, db.Close())
 	}
+	assert.Equal(t, []string{"gzip", ""}, acceptEncoding)
 }
 
-func TestConfigWithoutSSLCertPath(t *testing.T) {
+func TestConfigCompressionDisabledWithCustomClient(t *testing.T) {
 	c := &Config{
-		ServerURI:         "https://foobar@localhost:8080",
-		SessionProperties: map[string]string{"query_priority": "1"},
+		ServerURI:           "http://foobar@localhost:8080",
+		CustomClientName:    "custom",
+		CompressionDisabled: true,
 	}
-	dsn, err := c.FormatDSN()
-	require.NoError(t, err)
-
-	want := "https://foobar@localhost:8080?session_properties=query_priority%3A1&source=trino-go-client"
 
-	assert.Equal(t, want, dsn)
+	_, err := c.FormatDSN()
+	assert.Error(t, err)
 }
 
-func TestKerberosConfig(t *testing.T) {
+func TestConfigHTTP2(t *testing.T) {
 	c := &Config{
-		ServerURI:                 "https://foobar@localhost:8090",
-		SessionProperties:         map[string]string{"query_priority": "1"},
-		KerberosEnabled:           "true",
-		KerberosKeytabPath:        "/opt/test.keytab",
-		KerberosPrincipal:         "trino/testhost",
-		KerberosRealm:             "example.com",
-		KerberosConfigPath:        "/etc/krb5.conf",
-		KerberosRemoteServiceName: "service",
-		SSLCertPath:               "/tmp/test.cert",
+		ServerURI: "http://foobar@localhost:8080",
+		HTTP2:     true,
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	want := "https://foobar@localhost:8090?KerberosConfigPath=%2Fetc%2Fkrb5.conf&KerberosEnabled=true&KerberosKeytabPath=%2Fopt%2Ftest.keytab&KerberosPrincipal=trino%2Ftesthost&KerberosRealm=example.com&KerberosRemoteServiceName=service&SSLCertPath=%2Ftmp%2Ftest.cert&session_properties=query_priority%3A1&source=trino-go-client"
-
+	want := "http://foobar@localhost:8080?http2=true&source=trino-go-client"
 	assert.Equal(t, want, dsn)
-}
 
-func TestInvalidKerberosConfig(t *testing.T) {
-	c := &Config{
-		ServerURI:       "http://foobar@localhost:8090",
-		KerberosEnabled: "true",
-	}
-
-	_, err := c.FormatDSN()
-	assert.Error(t, err, "dsn generated from invalid secure url, since kerberos enabled must has SSL enabled")
+	c.CustomClientName = "custom"
+	_, err = c.FormatDSN()
+	assert.Error(t, err)
 }
 
-func TestAccessTokenConfig(t *testing.T) {
+func TestConfigAdditionalQueryParameters(t *testing.T) {
 	c := &Config{
 		ServerURI:   "https://foobar@localhost:8090",
+		Catalog:     "hive",
 		AccessToken: "token",
+		AdditionalQueryParameters: map[string]string{
+			"newHint":     "a b",
+			"catalog":     "other",
+			"accessToken": "other",
+		},
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
-
-	want := "https://foobar@localhost:8090?accessToken=token&source=trino-go-client"
-
+	want := "https://foobar@localhost:8090?accessToken=token&catalog=hive&newHint=a+b&source=trino-go-client"
 	assert.Equal(t, want, dsn)
-}
 
-func TestConfigWithMalformedURL(t *testing.T) {
-	_, err := (&Config{ServerURI: ":("}).FormatDSN()
-	assert.Error(t, err, "dsn generated from malformed url")
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"newHint": "a b"}, parsed.AdditionalQueryParameters)
+
+	c.AdditionalQueryParameters = map[string]string{"": "x"}
+	_, err = c.FormatDSN()
+	assert.Error(t, err, "empty parameter name")
 }
 
-func TestConnErrorDSN(t *testing.T) {
-	testcases := []struct {
-		Name string
-		DSN  string
-	}{
-		{Name: "malformed", DSN: "://"},
-		{Name: "unknown_client", DSN: "http://localhost?custom_client=unknown"},
+func TestConfigTimeouts(t *testing.T) {
+	c := &Config{
+		ServerURI:      "http://foobar@localhost:8080",
+		ConnectTimeout: 5 * time.Second,
+		QueryTimeout:   90 * time.Second,
 	}
 
-	for _, tc := range testcases {
-		t.Run(tc.Name, func(t *testing.T) {
-			db, err := sql.Open("trino", tc.DSN)
-			require.NoError(t, err)
-
-			_, err = db.Query("SELECT 1")
-			assert.Errorf(t, err, "test dsn is supposed to fail: %s", tc.DSN)
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
 
-			if err == nil {
-				require.NoError(t, db.Close())
-			}
-		})
-	}
-}
+	want := "http://foobar@localhost:8080?connectTimeout=5s&queryTimeout=1m30s&source=trino-go-client"
+	assert.Equal(t, want, dsn)
 
-func TestRegisterCustomClientReserved(t *testing.T) {
-	for _, tc := range []string{"true", "false"} {
-		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
-			require.Errorf(t,
-				RegisterCustomClient(tc, &http.Client{}),
-				"client key name supposed to fail: %s", tc)
-		})
+	c.CustomClientName = "custom"
+	_, err = c.FormatDSN()
+	assert.Error(t, err, "connect timeout with a custom client")
+
+	c.CustomClientName = ""
+	c.QueryTimeout = -time.Second
+	_, err = c.FormatDSN()
+	assert.Error(t, err, "negative timeout")
+
+	for _, dsn := range []string{
+		"http://foobar@localhost:8080?connectTimeout=a",
+		"http://foobar@localhost:8080?queryTimeout=10",
+	} {
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+		assert.Error(t, db.Ping(), dsn)
+		assert.NoError(t, db.Close())
 	}
 }
 
-func TestRoundTripRetryQueryError(t *testing.T) {
-	count := 0
+func TestQueryTimeout(t *testing.T) {
+	done := make(chan struct{})
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if count == 0 {
-			count++
-			w.WriteHeader(http.StatusServiceUnavailable)
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(&stmtResponse{
-			Error: ErrTrino{
-				ErrorName: "TEST",
-			},
-		})
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
 	}))
-
 	t.Cleanup(ts.Close)
+	t.Cleanup(func() { close(done) })
 
-	db, err := sql.Open("trino", ts.URL)
+	db, err := sql.Open("trino", ts.URL+"?queryTimeout=50ms")
 	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
 
-	t.Cleanup(func() {
-		assert.NoError(t, db.Close())
-	})
-
-	_, err = db.Query("SELECT 1")
-	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
+	start := time.Now()
+	_, err = db.Exec("SELECT 1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
 }
 
-func TestRoundTripBogusData(t *testing.T) {
-	count := 0
+func TestKeepAlive(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if count == 0 {
-			count++
-			w.WriteHeader(http.StatusServiceUnavailable)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-		// some invalid JSON
-		w.Write([]byte(`{"stats": {"progressPercentage": ""}}`))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
 	}))
-
 	t.Cleanup(ts.Close)
 
-	db, err := sql.Open("trino", ts.URL)
+	c := &Config{ServerURI: ts.URL, KeepAlive: time.Minute, KeepAliveInterval: 10 * time.Second}
+	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"?keepAlive=1m0s&keepAliveInterval=10s&source=trino-go-client", dsn)
 
-	t.Cleanup(func() {
-		assert.NoError(t, db.Close())
-	})
+	conn, err := newConn(dsn)
+	require.NoError(t, err)
+	assert.NotNil(t, conn.httpClient.Transport.(*http.Transport).DialContext)
 
-	rows, err := db.Query("SELECT 1")
+	db, err := sql.Open("trino", dsn)
 	require.NoError(t, err)
-	assert.False(t, rows.Next())
-	require.NoError(t, rows.Err())
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+
+	c = &Config{ServerURI: ts.URL, KeepAlive: -1}
+	dsn, err = c.FormatDSN()
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"?keepAlive=-1ns&source=trino-go-client", dsn)
+
+	for _, c := range []*Config{
+		{ServerURI: ts.URL, KeepAlive: time.Minute, CustomClientName: "custom"},
+		{ServerURI: ts.URL, KeepAliveInterval: time.Second, CustomClientName: "custom"},
+		{ServerURI: ts.URL, KeepAliveInterval: -time.Second},
+		{ServerURI: ts.URL, KeepAlive: -1, KeepAliveInterval: time.Second},
+	} {
+		_, err := c.FormatDSN()
+		assert.Error(t, err)
+	}
 }
 
-func TestRoundTripCancellation(t *testing.T) {
+func TestSocketTimeout(t *testing.T) {
+	done := make(chan struct{})
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
 	}))
-
 	t.Cleanup(ts.Close)
+	t.Cleanup(func() { close(done) })
 
-	db, err := sql.Open("trino", ts.URL)
+	c := &Config{ServerURI: ts.URL, SocketTimeout: 50 * time.Millisecond}
+	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"?socketTimeout=50ms&source=trino-go-client", dsn)
 
-	t.Cleanup(func() {
-		assert.NoError(t, db.Close())
-	})
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	start := time.Now()
+	_, err = db.Exec("SELECT 1")
+	var qferr *ErrQueryFailed
+	assert.ErrorAs(t, err, &qferr)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	for _, c := range []*Config{
+		{ServerURI: ts.URL, SocketTimeout: time.Second, CustomClientName: "custom"},
+		{ServerURI: ts.URL, SocketTimeout: time.Second, HTTP2: true},
+		{ServerURI: ts.URL, SocketTimeout: -time.Second},
+	} {
+		_, err := c.FormatDSN()
+		assert.Error(t, err)
+	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
-	t.Cleanup(cancel)
+func TestConnectTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
 
-	_, err = db.QueryContext(ctx, "SELECT 1")
-	assert.Error(t, err, "unexpected query with cancelled context succeeded")
+	// the listener accepts TCP connections but never completes the TLS handshake
+	db, err := sql.Open("trino", "https://foobar@"+ln.Addr().String()+"?connectTimeout=50ms")
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
 }
 
-func TestAuthFailure(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusUnauthorized)
+func TestCloseIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
 	}))
-
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	ts.Start()
 	t.Cleanup(ts.Close)
 
-	db, err := sql.Open("trino", ts.URL)
+	db, err := sql.Open("trino", ts.URL+"?connectTimeout=5s")
 	require.NoError(t, err)
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
 
-	assert.NoError(t, db.Close())
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle connection not closed with the connection")
+	}
 }
 
-func TestTokenAuth(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Authorization") != "Bearer token" {
-			w.WriteHeader(http.StatusUnauthorized)
-		} else {
-			w.WriteHeader(http.StatusOK)
-		}
-	}))
+func TestHTTP2(t *testing.T) {
+	var protocols []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protocols = append(protocols, r.Proto)
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	})
 
-	t.Cleanup(ts.Close)
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
 
-	db, err := sql.Open("trino", ts.URL+"?accessToken=token")
-	require.NoError(t, err)
+	h2cServer := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	t.Cleanup(h2cServer.Close)
 
-	_, err = db.Query("SELECT 1")
-	require.Error(t, err, "trino: EOF")
+	for _, c := range []*Config{
+		{ServerURI: tlsServer.URL, SSLCert: string(cert), HTTP2: true},
+		{ServerURI: h2cServer.URL, HTTP2: true},
+		{ServerURI: h2cServer.URL},
+	} {
+		dsn, err := c.FormatDSN()
+		require.NoError(t, err)
+
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+		assert.NoError(t, db.Close())
+	}
+	assert.Equal(t, []string{"HTTP/2.0", "HTTP/2.0", "HTTP/1.1"}, protocols)
+
+	// over TLS, HTTP/2 is negotiated without the http2 parameter, also with custom TLS settings
+	protocols = nil
+	dsn, err := (&Config{ServerURI: tlsServer.URL, SSLCert: string(cert)}).FormatDSN()
+	require.NoError(t, err)
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.NoError(t, db.Close())
+	assert.Equal(t, []string{"HTTP/2.0"}, protocols)
 
+	_, err = (&Config{ServerURI: h2cServer.URL, HTTP2: true, SocketTimeout: time.Second}).FormatDSN()
+	assert.Error(t, err)
+	db, err = sql.Open("trino", h2cServer.URL+"?http2=true&socketTimeout=1s")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT 1")
+	assert.ErrorContains(t, err, "socketTimeout cannot be combined with http2")
 	assert.NoError(t, db.Close())
 }
 
-func TestQueryForUsername(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping test in short mode.")
+func TestHostVerification(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	hostVerification := false
+	c := &Config{ServerURI: ts.URL, HostVerification: &hostVerification}
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"?hostVerification=false&source=trino-go-client", dsn)
+
+	for dsn, wantErr := range map[string]bool{
+		ts.URL:                            true,
+		ts.URL + "?hostVerification=true": true,
+		dsn:                               false,
+	} {
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+		_, err = db.Exec("SELECT 1")
+		if wantErr {
+			assert.Error(t, err, dsn)
+		} else {
+			assert.NoError(t, err, dsn)
+		}
+		assert.NoError(t, db.Close())
+	}
+
+	for _, c := range []*Config{
+		{ServerURI: "http://foobar@localhost:8080", HostVerification: &hostVerification},
+		{ServerURI: "https://foobar@localhost:8080", HostVerification: &hostVerification, CustomClientName: "custom"},
+	} {
+		_, err := c.FormatDSN()
+		assert.Error(t, err, c.ServerURI)
 	}
+}
+
+func TestConfigSSLClientCert(t *testing.T) {
 	c := &Config{
-		ServerURI:         *integrationServerFlag,
-		SessionProperties: map[string]string{"query_priority": "1"},
+		ServerURI:         "https://foobar@localhost:8090",
+		SSLClientCertPath: "/tmp/client.pem",
+		SSLClientKeyPath:  "/tmp/client.key",
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	db, err := sql.Open("trino", dsn)
+	want := "https://foobar@localhost:8090?SSLClientCertPath=%2Ftmp%2Fclient.pem&SSLClientKeyPath=%2Ftmp%2Fclient.key&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	for _, c := range []*Config{
+		{ServerURI: "http://foobar@localhost:8090", SSLClientCert: "cert", SSLClientKey: "key"},
+		{ServerURI: "https://foobar@localhost:8090", SSLClientCertPath: "/tmp/client.pem"},
+		{ServerURI: "https://foobar@localhost:8090", SSLClientKey: "key"},
+		{ServerURI: "https://foobar@localhost:8090", SSLClientCertPath: "/tmp/client.pem", SSLClientKeyPath: "/tmp/client.key", SSLClientCert: "cert", SSLClientKey: "key"},
+		{ServerURI: "https://foobar@localhost:8090", SSLClientCert: "cert", SSLClientKey: "key", CustomClientName: "custom"},
+	} {
+		_, err := c.FormatDSN()
+		assert.Error(t, err, "invalid client certificate config %+v formatted with no error", c)
+	}
+}
+
+func TestSSLClientCert(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, generateCerts(dir))
+	certPEM, err := os.ReadFile(dir + "/certificate.pem")
+	require.NoError(t, err)
+	keyPEM, err := os.ReadFile(dir + "/private_key.pem")
 	require.NoError(t, err)
 
-	t.Cleanup(func() {
-		assert.NoError(t, db.Close())
-	})
+	var clientCerts []int
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts = append(clientCerts, len(r.TLS.PeerCertificates))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
 
-	rows, err := db.Query("SELECT current_user", sql.Named("X-Trino-User", string("TestUser")))
-	require.NoError(t, err, "Failed executing query")
-	assert.NotNil(t, rows)
+	for _, c := range []*Config{
+		{ServerURI: ts.URL, SSLCert: string(serverCert), SSLClientCertPath: dir + "/certificate.pem", SSLClientKeyPath: dir + "/private_key.pem"},
+		{ServerURI: ts.URL, SSLCert: string(serverCert), SSLClientCert: string(certPEM), SSLClientKey: string(keyPEM)},
+	} {
+		dsn, err := c.FormatDSN()
+		require.NoError(t, err)
 
-	for rows.Next() {
-		var user string
-		require.NoError(t, rows.Scan(&user), "Failed scanning query result")
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
 
-		assert.Equal(t, "TestUser", user, "Expected value does not equal result value")
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+		assert.NoError(t, db.Close())
 	}
-}
+	assert.Equal(t, []int{1, 1}, clientCerts)
 
-type TestQueryProgressCallback struct {
-	progressMap map[time.Time]float64
-	statusMap   map[time.Time]string
+	db, err := sql.Open("trino", "https://localhost:8090?SSLClientCertPath=/nonexistent&SSLClientKeyPath=/nonexistent")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT 1")
+	assert.ErrorContains(t, err, "Error loading SSL client certificate files")
+	assert.NoError(t, db.Close())
 }
 
-func (qpc *TestQueryProgressCallback) Update(qpi QueryProgressInfo) {
-	qpc.progressMap[time.Now()] = float64(qpi.QueryStats.ProgressPercentage)
-	qpc.statusMap[time.Now()] = qpi.QueryStats.State
+func TestConfigApplicationName(t *testing.T) {
+	c := &Config{
+		ServerURI:       "http://foobar@localhost:8080",
+		ApplicationName: "my service",
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?application_name=my+service&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	var clientInfo string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientInfo = r.Header.Get(trinoClientInfoHeader)
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?application_name=my+service")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "my service", clientInfo)
 }
 
-func TestQueryProgressWithCallback(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping test in short mode.")
-	}
+func TestConfigLocale(t *testing.T) {
 	c := &Config{
-		ServerURI:         *integrationServerFlag,
-		SessionProperties: map[string]string{"query_priority": "1"},
+		ServerURI: "http://foobar@localhost:8080",
+		Locale:    "en-US",
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	db, err := sql.Open("trino", dsn)
+	want := "http://foobar@localhost:8080?locale=en-US&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "en-US", parsed.Locale)
+
+	for _, locale := range []string{"en_US", "en-", "en US", "français"} {
+		_, err = (&Config{ServerURI: "http://foobar@localhost:8080", Locale: locale}).FormatDSN()
+		assert.Error(t, err, locale)
+	}
+
+	var language string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language = r.Header.Get(trinoLanguageHeader)
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?locale=de-DE")
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	callback := &TestQueryProgressCallback{}
-
-	_, err = db.Query("SELECT 2", sql.Named("X-Trino-Progress-Callback", callback))
-	assert.EqualError(t, err, ErrInvalidProgressCallbackHeader.Error(), "unexpected error")
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "de-DE", language)
 }
 
-func TestQueryProgressWithCallbackPeriod(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping test in short mode.")
-	}
+func TestConfigTimeZone(t *testing.T) {
 	c := &Config{
-		ServerURI:         *integrationServerFlag,
-		SessionProperties: map[string]string{"query_priority": "1"},
+		ServerURI: "http://foobar@localhost:8080",
+		TimeZone:  "America/New_York",
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	db, err := sql.Open("trino", dsn)
+	want := "http://foobar@localhost:8080?source=trino-go-client&timeZone=America%2FNew_York"
+	assert.Equal(t, want, dsn)
+
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", parsed.TimeZone)
+
+	_, err = (&Config{ServerURI: "http://foobar@localhost:8080", TimeZone: "America/New York"}).FormatDSN()
+	assert.Error(t, err)
+
+	var timeZone string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeZone = r.Header.Get(trinoTimeZoneHeader)
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?timeZone=%2B05%3A30")
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	progressMap := make(map[time.Time]float64)
-	statusMap := make(map[time.Time]string)
-	progressUpdater := &TestQueryProgressCallback{
-		progressMap: progressMap,
-		statusMap:   statusMap,
+	_, err = db.Exec("SELECT current_timezone()")
+	require.NoError(t, err)
+	assert.Equal(t, "+05:30", timeZone)
+}
+
+func TestConfigTraceToken(t *testing.T) {
+	c := &Config{
+		ServerURI:       "http://foobar@localhost:8080",
+		QueryTraceToken: "billing-service",
 	}
-	progressUpdaterPeriod, err := time.ParseDuration("1ms")
+
+	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	rows, err := db.Query("SELECT 2",
-		sql.Named("X-Trino-Progress-Callback", progressUpdater),
-		sql.Named("X-Trino-Progress-Callback-Period", progressUpdaterPeriod),
-	)
-	require.NoError(t, err, "Failed executing query")
-	assert.NotNil(t, rows)
+	want := "http://foobar@localhost:8080?source=trino-go-client&traceToken=billing-service"
+	assert.Equal(t, want, dsn)
 
-	for rows.Next() {
-		var ts string
-		require.NoError(t, rows.Scan(&ts), "Failed scanning query result")
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "billing-service", parsed.QueryTraceToken)
 
-		assert.Equal(t, "2", ts, "Expected value does not equal result value")
+	_, err = (&Config{ServerURI: "http://foobar@localhost:8080", QueryTraceToken: "billing service"}).FormatDSN()
+	assert.Error(t, err)
+
+	var tokens []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get(trinoTraceTokenHeader))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tc := range []struct {
+		dsn      string
+		expected []string
+	}{
+		{ts.URL, []string{"", "query-1"}},
+		{ts.URL + "?traceToken=billing-service", []string{"billing-service", "query-1"}},
+	} {
+		tokens = nil
+		db, err := sql.Open("trino", tc.dsn)
+		require.NoError(t, err)
+		ctx := context.Background()
+		_, err = db.ExecContext(ctx, "SELECT 1")
+		require.NoError(t, err)
+		_, err = db.ExecContext(WithTraceToken(ctx, "query-1"), "SELECT 1")
+		require.NoError(t, err)
+		assert.NoError(t, db.Close())
+		assert.Equal(t, tc.expected, tokens, "DSN %s", tc.dsn)
 	}
+}
 
-	if err = rows.Err(); err != nil {
-		t.Fatal(err)
+func TestConfigCatalogSchema(t *testing.T) {
+	c := &Config{
+		ServerURI: "http://foobar@localhost:8080",
+		Catalog:   "tpch",
+		Schema:    "sf1",
 	}
-	if err = rows.Close(); err != nil {
-		t.Fatal(err)
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?catalog=tpch&schema=sf1&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	conn, err := newConn(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "tpch", conn.httpHeaders.Get(trinoCatalogHeader))
+	assert.Equal(t, "sf1", conn.httpHeaders.Get(trinoSchemaHeader))
+}
+
+func TestParseDSN(t *testing.T) {
+	c := &Config{
+		ServerURI:                  "https://foobar@localhost:8090",
+		Source:                     "my-source",
+		ApplicationName:            "my service",
+		UserAgent:                  "my-service/1.0",
+		QueryTraceToken:            "my-service",
+		Catalog:                    "hive",
+		Schema:                     "default",
+		SessionProperties:          map[string]string{"query_priority": "1", "query_max_run_time": "10m"},
+		ExtraCredentials:           map[string]string{"token": "a:b"},
+		Roles:                      map[string]string{"hive": "ROLE{admin}", "system": "ALL"},
+		ForwardedHeaders:           map[string]string{"X-Request-Id": "abc"},
+		KerberosEnabled:            "true",
+		KerberosKeytabPath:         "/opt/test.keytab",
+		KerberosPrincipal:          "trino/testhost",
+		KerberosRemoteServiceName:  "trino",
+		KerberosRealm:              "example.com",
+		KerberosConfigPath:         "/etc/krb5.conf",
+		KerberosServiceKDCHostname: "kdc.example.com:8888",
+		SSLCertPath:                "/tmp/test.cert",
+		AccessToken:                "token",
+		ExplicitPrepare:            true,
+		ForwardAuthorizationHeader: true,
+		CompressionDisabled:        true,
+		MaxResponseBodySize:        1024,
+		ReadBufferSize:             65536,
+		ServerStartupRetries:       3,
+		ConnectTimeout:             5 * time.Second,
+		SocketTimeout:              30 * time.Second,
+		KeepAlive:                  time.Minute,
+		KeepAliveInterval:          10 * time.Second,
+		QueryTimeout:               10 * time.Minute,
+		TraceQueryText:             true,
+		EnableQueryInfo:            true,
+		RowValues:                  true,
+		HostVerification:           new(bool),
 	}
 
-	// sort time in order to calculate interval
-	assert.NotEmpty(t, progressMap)
-	assert.NotEmpty(t, statusMap)
-	var keys []time.Time
-	for k := range statusMap {
-		keys = append(keys, k)
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	parsed, err = ParseDSN("http://foobar@localhost:8080?unknown=1")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{ServerURI: "http://foobar@localhost:8080", AdditionalQueryParameters: map[string]string{"unknown": "1"}}, parsed)
+
+	// known parameters with default values are not formatted, but still recognized
+	parsed, err = ParseDSN("http://foobar@localhost:8080?compressionDisabled=false&catalog=&explicitPrepare=true")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{ServerURI: "http://foobar@localhost:8080", ExplicitPrepare: true}, parsed)
+
+	for _, dsn := range []string{
+		"http://foobar@localhost:8080?session_properties=query_priority",
+		"http://foobar@localhost:8080?roles=:ALL",
+		"http://foobar@localhost:8080?maxResponseBodySize=a",
+		"http://foobar@localhost:8080?queryTimeout=a",
+		"://localhost",
+	} {
+		_, err := ParseDSN(dsn)
+		assert.Error(t, err, "invalid DSN %q parsed with no error", dsn)
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i].Before(keys[j])
-	})
+}
 
-	for i, k := range keys {
-		if i > 0 {
-			assert.GreaterOrEqual(t, k.Sub(keys[i-1]), progressUpdaterPeriod)
-		}
-		assert.GreaterOrEqual(t, progressMap[k], 0.0)
+func TestConfigSchemaWithoutCatalog(t *testing.T) {
+	c := &Config{
+		ServerURI: "http://foobar@localhost:8080",
+		Schema:    "sf1",
 	}
+
+	_, err := c.FormatDSN()
+	assert.EqualError(t, err, "trino: client configuration error, a schema cannot be specified without a catalog")
 }
 
-func TestQueryColumns(t *testing.T) {
+func TestConfigSSLCertPath(t *testing.T) {
 	c := &Config{
-		ServerURI:         *integrationServerFlag,
+		ServerURI:         "https://foobar@localhost:8080",
 		SessionProperties: map[string]string{"query_priority": "1"},
+		SSLCertPath:       "cert.pem",
 	}
 
 	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	db, err := sql.Open("trino", dsn)
+	want := "https://foobar@localhost:8080?SSLCertPath=cert.pem&session_properties=query_priority%3A1&source=trino-go-client"
+
+	assert.Equal(t, want, dsn)
+}
+
+func TestConfigSSLCert(t *testing.T) {
+	sslCert := `-----BEGIN CERTIFICATE-----
+MIIFijCCA3ICCQDngXKCZFwSazANBgkqhkiG9w0BAQsFADCBhjELMAkGA1UEBhMC
+WFgxEjAQBgNVBAgMCVN0YXRlTmFtZTERMA8GA1UEBwwIQ2l0eU5hbWUxFDASBgNV
+BAoMC0NvbXBhbnlOYW1lMRswGQYDVQQLDBJDb21wYW55U2VjdGlvbk5hbWUxHTAb
+BgNVBAMMFENvbW1vbk5hbWVPckhvc3RuYW1lMB4XDTIzMDUxNzE2MzQ0MloXDTMz
+MDUxNDE2MzQ0MlowgYYxCzAJBgNVBAYTAlhYMRIwEAYDVQQIDAlTdGF0ZU5hbWUx
+ETAPBgNVBAcMCENpdHlOYW1lMRQwEgYDVQQKDAtDb21wYW55TmFtZTEbMBkGA1UE
+CwwSQ29tcGFueVNlY3Rpb25OYW1lMR0wGwYDVQQDDBRDb21tb25OYW1lT3JIb3N0
+bmFtZTCCAiIwDQYJKoZIhvcNAQEBBQADggIPADCCAgoCggIBAKzz/SIuOiHZbUAH
+xCWrMaiJybdHHHl0smCu50XKvl/ZkszO1c4aES8/Vohw44ttaE+GOknTSGPka356
+NqwdPYMjnXN0d5HY5T5nOfgLxGD/1iCHACrT4gkd1asJ7eFaUgud0a+e9+oG53Vh
+Z3QV8+5JaWPuBMudJ8EOtrPMd0dJKVzeExTbpQLJ9HdIsHc6DXqshACd8Iy+ezqf
+OoYMYyJMAHO86MZrTs3t9AwUADlvntrwwObVrZ3v43IOKwJTRnpImmVlkouKrGn/
+HKzRmJEJ6hJQXhuhqI/0rr61XR8aa8Gs0FqtTTMJ32+PciPPzFtFVLAeA417lYz+
+uXZ6IpTLK4oDH8Q6gJY80GYqcGc+01ZY90W2L+odTz9P74vnTvsUgSjOcy7prJ0+
+WxoeBNPvkLeetX9WDZW4XaR++HVO1qelNJQqeB6Nver9MJdKkXvR3OxT6iluqXfA
+l9JJ57tnzspSrttjWG4kwwiaGn/4xPqd95Hp0r1WAK8U0Cqtvz+Zw9jl341tC1Ya
+K1KFIErZYf0KX8ZiYvmkHaTRxYiCmFnnfLtGdrAWkacisLKMhjeb9LXwC/TVtvio
+a+ofiW2DX80pQptkfNJs9P19ZFEojPAEFHiZFpz5yZSxHglxIsdIhRsuy5xb/KTo
+zey3tsKQJaFIah+aHKjyn3uZx2IRAgMBAAEwDQYJKoZIhvcNAQELBQADggIBAIs5
+sbCMB6bT0hcNFqFRCI/BL23m5jwdL9kNWDlEQxBvErtzTC+uStGrCqwV+qu49QAZ
+64kUolbzFyq/hQFpHd+9EzNkZGbiOf5toWaBUP6jaZzqYPdfDW+AwIA7iPHcqwH1
+iWX2zuAWAICy4H+S4oa/ShOPc8BrrnS8k5f1NpergOhd+wl+szuXJN9Tjli3wd/k
+L7f86xvZfOrEbss8YP4QE0+mKh6G71NLEVQ4SV7yIE2hCNLDFWS2ltGVRLv6CDaQ
+fXIQrZx2Khvpj+HI/hrwm1wV8Cg5w2IvB831YjTSepSoos0Cc/qYC78zqol/NbwL
+7TdHtuZKukDrisRiCDdoKFmS1/IUVeVR2352CG8G3Zo0wwfzoKLxLUtunnrKMmmO
+r2jXykqP2hb1dApBNFM7FoaJ7a0j6EcURW8wYl4I+b9ymftPnnZ8mgrjwvLh5ETj
+RgGsIBychLZoc1WWTZWu62+mvmSJnzEIFfaiSeYZLaL6qFHm6kqsAUn4s1Looj8/
+XoCNjMecchWbpHGCPwMFH1k2smxu7bKk/RJNuWSVn1IPUceJnOBHZGj92aJGZpjr
+8j39T3dK9F2r5rHwjZpeEIhyhbLw6pYKif+lBgAWJD3waG0ycwURA02/POHN4CpT
+FKu5ZAlRfb2aYegr49DHhzoVAdInWQmP+5EZEUD1
+-----END CERTIFICATE-----`
+	c := &Config{
+		ServerURI:         "https://foobar@localhost:8080",
+		SessionProperties: map[string]string{"query_priority": "1"},
+		SSLCert:           sslCert,
+	}
+
+	dsn, err := c.FormatDSN()
 	require.NoError(t, err)
 
-	t.Cleanup(func() {
-		assert.NoError(t, db.Close())
-	})
+	want := "https://foobar@localhost:8080?SSLCert=" + url.QueryEscape(sslCert) + "&session_properties=query_priority%3A1&source=trino-go-client"
 
-	rows, err := db.Query(`SELECT
-  true AS bool,
-  cast(123 AS tinyint) AS tinyint,
-  cast(456 AS smallint) AS smallint,
-  cast(678 AS integer) AS integer,
-  cast(1234 AS bigint) AS bigint,
-  cast(1.23 AS real) AS real,
-  cast(1.23 AS double) AS double,
-  cast(1.23 as decimal(10,5)) AS decimal,
-  cast('aaa' as varchar) AS vunbounded,
-  cast('bbb' as varchar(10)) AS vbounded,
-  cast('ccc' AS char) AS cunbounded,
-  cast('ddd' as char(10)) AS cbounded,
-  cast('ddd' as varbinary) AS varbinary,
-  cast('{"aaa": 1}' as json) AS json,
-  current_date AS date,
-  cast(current_time as time) AS time,
-  cast(current_time as time(6)) AS timep,
-  cast(current_time as time with time zone) AS timetz,
-  cast(current_time as timestamp) AS ts,
-  cast(current_time as timestamp(6)) AS tsp,
-  cast(current_time as timestamp with time zone) AS tstz,
-  cast(current_time as timestamp(6) with time zone) AS tsptz,
-  interval '3' month AS ytm,
-  interval '2' day AS dts,
-  array['a', 'b'] AS varray,
-  array[array['a'], array['b']] AS v2array,
-  array[array[array['a'], array['b']]] AS v3array,
-  map(array['a'], array[1]) AS map,
-  array[map(array['a'], array[1]), map(array['b'], array[2])] AS marray,
-  row('a', 1) AS row,
-  cast(row('a', 1.23) AS row(x varchar, y double)) AS named_row,
-  ipaddress '10.0.0.1' AS ip,
-  uuid '12151fd2-7586-11e9-8f9e-2a86e4085a59' AS uuid`)
-	require.NoError(t, err, "Failed executing query")
-	assert.NotNil(t, rows)
+	assert.Equal(t, want, dsn)
+}
 
-	columns, err := rows.Columns()
-	require.NoError(t, err, "Failed reading result columns")
+func TestExtraCredentials(t *testing.T) {
+	c := &Config{
+		ServerURI:        "http://foobar@localhost:8080",
+		ExtraCredentials: map[string]string{"token": "mYtOkEn", "otherToken": "oThErToKeN%*!#@special"},
+	}
 
-	assert.Equal(t, 33, len(columns), "Expected 33 result column")
-	expectedNames := []string{
-		"bool",
-		"tinyint",
-		"smallint",
-		"integer",
-		"bigint",
-		"real",
-		"double",
-		"decimal",
-		"vunbounded",
-		"vbounded",
-		"cunbounded",
-		"cbounded",
-		"varbinary",
-		"json",
-		"date",
-		"time",
-		"timep",
-		"timetz",
-		"ts",
-		"tsp",
-		"tstz",
-		"tsptz",
-		"ytm",
-		"dts",
-		"varray",
-		"v2array",
-		"v3array",
-		"map",
-		"marray",
-		"row",
-		"named_row",
-		"ip",
-		"uuid",
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?extra_credentials=otherToken%3AoThErToKeN%25%2A%21%23%40special%3Btoken%3AmYtOkEn&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+}
+
+func TestConfigRoles(t *testing.T) {
+	c := &Config{
+		ServerURI: "http://foobar@localhost:8080",
+		Roles:     map[string]string{"system": "ROLE{admin}", "hive": "ALL"},
 	}
-	assert.Equal(t, expectedNames, columns)
 
-	columnTypes, err := rows.ColumnTypes()
-	require.NoError(t, err, "Failed reading result column types")
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
 
-	assert.Equal(t, 33, len(columnTypes), "Expected 33 result column type")
+	want := "http://foobar@localhost:8080?roles=hive%3AALL%3Bsystem%3AROLE%7Badmin%7D&source=trino-go-client"
+	assert.Equal(t, want, dsn)
 
-	type columnType struct {
-		typeName  string
-		hasScale  bool
-		precision int64
-		scale     int64
-		hasLength bool
-		length    int64
-		scanType  reflect.Type
+	conn, err := newConn(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hive=ALL", "system=ROLE%7Badmin%7D"}, conn.httpHeaders.Values(trinoRoleHeader))
+}
+
+func TestInvalidConfigRoles(t *testing.T) {
+	for _, roles := range []map[string]string{
+		{"system": "admin"},
+		{"system": "ROLE{}"},
+		{"system": "all"},
+		{"": "ALL"},
+	} {
+		c := &Config{
+			ServerURI: "http://foobar@localhost:8080",
+			Roles:     roles,
+		}
+		_, err := c.FormatDSN()
+		assert.Error(t, err, "invalid roles %v formatted with no error", roles)
 	}
-	expectedTypes := []columnType{
-		{
-			"BOOLEAN",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullBool{}),
-		},
-		{
-			"TINYINT",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullInt32{}),
-		},
-		{
-			"SMALLINT",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullInt32{}),
-		},
-		{
-			"INTEGER",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullInt32{}),
-		},
-		{
-			"BIGINT",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullInt64{}),
-		},
-		{
-			"REAL",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullFloat64{}),
-		},
-		{
-			"DOUBLE",
-			false,
-			0,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullFloat64{}),
-		},
-		{
-			"DECIMAL",
-			true,
-			10,
-			5,
-			false,
-			0,
-			reflect.TypeOf(sql.NullString{}),
-		},
+}
+
+func TestConfigForwardedHeaders(t *testing.T) {
+	c := &Config{
+		ServerURI:        "http://foobar@localhost:8080",
+		ForwardedHeaders: map[string]string{"X-Forwarded-For": "10.0.0.1", "X-Request-ID": "abc"},
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?forwarded_headers=X-Forwarded-For%3A10.0.0.1%3BX-Request-ID%3Aabc&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	var headers http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?"+strings.SplitN(dsn, "?", 2)[1])
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", headers.Get("X-Forwarded-For"))
+	assert.Equal(t, "abc", headers.Get("X-Request-ID"))
+
+	// values can contain the separators of the DSN parameter
+	c.ServerURI = ts.URL
+	c.ForwardedHeaders = map[string]string{"Forwarded": "for=1.2.3.4;proto=https", "X-Note": "a:b c%"}
+	dsn, err = c.FormatDSN()
+	require.NoError(t, err)
+
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, c.ForwardedHeaders, parsed.ForwardedHeaders)
+
+	db2, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, db2.Close())
+	})
+
+	_, err = db2.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "for=1.2.3.4;proto=https", headers.Get("Forwarded"))
+	assert.Equal(t, "a:b c%", headers.Get("X-Note"))
+}
+
+func TestInvalidConfigForwardedHeaders(t *testing.T) {
+	for _, headers := range []map[string]string{
+		{"": "a"},
+		{"X-Trino-User": "alice"},
+		{"authorization": "Bearer token"},
+		{"X-Request:ID": "abc"},
+		{"X-Request-ID": ""},
+		{"X-Request-ID": "a\r\nX-Trino-User: alice"},
+	} {
+		c := &Config{
+			ServerURI:        "http://foobar@localhost:8080",
+			ForwardedHeaders: headers,
+		}
+		_, err := c.FormatDSN()
+		assert.Error(t, err, "invalid forwarded headers %v formatted with no error", headers)
+	}
+}
+
+func TestInvalidExtraCredentials(t *testing.T) {
+	testcases := []struct {
+		Name        string
+		Credentials map[string]string
+		Error       string
+	}{
 		{
-			"VARCHAR",
-			false,
-			0,
-			0,
-			true,
-			math.MaxInt32,
-			reflect.TypeOf(sql.NullString{}),
+			Name:        "Empty key",
+			Credentials: map[string]string{"": "emptyKey"},
+			Error:       "trino: extra_credentials key is empty",
 		},
 		{
-			"VARCHAR",
-			false,
-			0,
-			0,
-			true,
-			10,
-			reflect.TypeOf(sql.NullString{}),
+			Name:        "Empty value",
+			Credentials: map[string]string{"valid": "a", "emptyValue": ""},
+			Error:       "trino: extra_credentials value is empty",
 		},
 		{
-			"CHAR",
-			false,
-			0,
-			0,
-			true,
-			1,
-			reflect.TypeOf(sql.NullString{}),
+			Name:        "Unprintable key",
+			Credentials: map[string]string{"😊": "unprintableKey"},
+			Error:       "trino: extra_credentials key '😊' contains spaces or is not printable ASCII",
 		},
 		{
-			"CHAR",
-			false,
-			0,
-			0,
-			true,
-			10,
-			reflect.TypeOf(sql.NullString{}),
+			Name:        "Unprintable value",
+			Credentials: map[string]string{"unprintableValue": "😊"},
+			Error:       "trino: extra_credentials value for key 'unprintableValue' contains spaces or is not printable ASCII",
+		},
+	}
+
+	for _, tc := range testcases {
+
+		t.Run(tc.Name, func(t *testing.T) {
+			c := &Config{
+				ServerURI:        "http://foobar@localhost:8080",
+				ExtraCredentials: tc.Credentials,
+			}
+			_, err := c.FormatDSN()
+			assert.EqualError(t, err, tc.Error)
+		})
+	}
+
+	// DSNs that are not formatted from a Config are validated when connecting
+	db, err := sql.Open("trino", "http://foobar@localhost:8080?extra_credentials=token%3A%F0%9F%98%8A")
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+	assert.EqualError(t, db.Ping(), "trino: extra_credentials value for key 'token' contains spaces or is not printable ASCII")
+}
+
+func TestConfigWithoutSSLCertPath(t *testing.T) {
+	c := &Config{
+		ServerURI:         "https://foobar@localhost:8080",
+		SessionProperties: map[string]string{"query_priority": "1"},
+	}
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "https://foobar@localhost:8080?session_properties=query_priority%3A1&source=trino-go-client"
+
+	assert.Equal(t, want, dsn)
+}
+
+func TestKerberosConfig(t *testing.T) {
+	c := &Config{
+		ServerURI:                 "https://foobar@localhost:8090",
+		SessionProperties:         map[string]string{"query_priority": "1"},
+		KerberosEnabled:           "true",
+		KerberosKeytabPath:        "/opt/test.keytab",
+		KerberosPrincipal:         "trino/testhost",
+		KerberosRealm:             "example.com",
+		KerberosConfigPath:        "/etc/krb5.conf",
+		KerberosRemoteServiceName: "service",
+		SSLCertPath:               "/tmp/test.cert",
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "https://foobar@localhost:8090?KerberosConfigPath=%2Fetc%2Fkrb5.conf&KerberosEnabled=true&KerberosKeytabPath=%2Fopt%2Ftest.keytab&KerberosPrincipal=trino%2Ftesthost&KerberosRealm=example.com&KerberosRemoteServiceName=service&SSLCertPath=%2Ftmp%2Ftest.cert&session_properties=query_priority%3A1&source=trino-go-client"
+
+	assert.Equal(t, want, dsn)
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var tokenRequests int32
+	var authorizations []string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			n := atomic.AddInt32(&tokenRequests, 1)
+			id, secret, _ := r.BasicAuth()
+			if id != "my-client" || secret != "my-secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "trino read" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+			return
+		}
+		authorizations = append(authorizations, r.Header.Get(authorizationHeader))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	require.NoError(t, RegisterCustomClient("oauth2", ts.Client()))
+	t.Cleanup(func() { DeregisterCustomClient("oauth2") })
+
+	c := &Config{
+		ServerURI:          ts.URL,
+		CustomClientName:   "oauth2",
+		OAuth2TokenURL:     ts.URL + "/oauth/token",
+		OAuth2ClientID:     "my-client",
+		OAuth2ClientSecret: "my-secret",
+		OAuth2Scopes:       []string{"trino", "read"},
+	}
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+	parsed, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, c.OAuth2TokenURL, parsed.OAuth2TokenURL)
+	assert.Equal(t, c.OAuth2ClientID, parsed.OAuth2ClientID)
+	assert.Equal(t, c.OAuth2ClientSecret, parsed.OAuth2ClientSecret)
+	assert.Equal(t, c.OAuth2Scopes, parsed.OAuth2Scopes)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+	db.SetMaxIdleConns(0)
+
+	// connections share the token until it expires
+	for i := 0; i < 2; i++ {
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-1"}, authorizations)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+
+	// tokens live as long as the sql.DB, and aren't shared with other ones
+	other, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	_, err = other.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.NoError(t, other.Close())
+	assert.Equal(t, "Bearer token-2", authorizations[2])
+
+	// token requests failing with invalid credentials fail the query
+	c.OAuth2ClientSecret = "wrong"
+	dsn, err = c.FormatDSN()
+	require.NoError(t, err)
+	db2, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db2.Close()) })
+	_, err = db2.Exec("SELECT 1")
+	assert.ErrorContains(t, err, "oauth2")
+}
+
+func TestOAuth2InvalidConfig(t *testing.T) {
+	for name, c := range map[string]*Config{
+		"unencrypted":    {ServerURI: "http://foobar@localhost:8080", OAuth2TokenURL: "https://auth.example.com/token", OAuth2ClientID: "id", OAuth2ClientSecret: "secret"},
+		"no secret":      {ServerURI: "https://foobar@localhost:8080", OAuth2TokenURL: "https://auth.example.com/token", OAuth2ClientID: "id"},
+		"no token URL":   {ServerURI: "https://foobar@localhost:8080", OAuth2ClientID: "id", OAuth2ClientSecret: "secret"},
+		"HTTP token URL": {ServerURI: "https://foobar@localhost:8080", OAuth2TokenURL: "http://auth.example.com/token", OAuth2ClientID: "id", OAuth2ClientSecret: "secret"},
+		"access token":   {ServerURI: "https://foobar@localhost:8080", OAuth2TokenURL: "https://auth.example.com/token", OAuth2ClientID: "id", OAuth2ClientSecret: "secret", AccessToken: "token"},
+		"invalid scope":  {ServerURI: "https://foobar@localhost:8080", OAuth2TokenURL: "https://auth.example.com/token", OAuth2ClientID: "id", OAuth2ClientSecret: "secret", OAuth2Scopes: []string{"a b"}},
+	} {
+		_, err := c.FormatDSN()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestKerberosServiceKDCHostname(t *testing.T) {
+	conf, err := config.NewFromString(`
+[libdefaults]
+  default_realm = EXAMPLE.COM
+[realms]
+  EXAMPLE.COM = {
+    kdc = kdc1.example.com
+    kdc = kdc2.example.com
+  }
+`)
+	require.NoError(t, err)
+
+	overrideKDC(conf, "", "trino-kdc.example.com")
+	n, kdcs, err := conf.GetKDCs("EXAMPLE.COM", true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "trino-kdc.example.com:88", kdcs[1])
+
+	overrideKDC(conf, "OTHER.COM", "trino-kdc.other.com:8888")
+	n, kdcs, err = conf.GetKDCs("OTHER.COM", true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "trino-kdc.other.com:8888", kdcs[1])
+}
+
+func TestInvalidKerberosConfig(t *testing.T) {
+	c := &Config{
+		ServerURI:       "http://foobar@localhost:8090",
+		KerberosEnabled: "true",
+	}
+
+	_, err := c.FormatDSN()
+	assert.Error(t, err, "dsn generated from invalid secure url, since kerberos enabled must has SSL enabled")
+}
+
+func TestAccessTokenConfig(t *testing.T) {
+	c := &Config{
+		ServerURI:   "https://foobar@localhost:8090",
+		AccessToken: "token",
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "https://foobar@localhost:8090?accessToken=token&source=trino-go-client"
+
+	assert.Equal(t, want, dsn)
+}
+
+func TestConfigWithMalformedURL(t *testing.T) {
+	_, err := (&Config{ServerURI: ":("}).FormatDSN()
+	assert.Error(t, err, "dsn generated from malformed url")
+}
+
+func TestConnErrorDSN(t *testing.T) {
+	testcases := []struct {
+		Name string
+		DSN  string
+	}{
+		{Name: "malformed", DSN: "://"},
+		{Name: "unknown_client", DSN: "http://localhost?custom_client=unknown"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			db, err := sql.Open("trino", tc.DSN)
+			require.NoError(t, err)
+
+			_, err = db.Query("SELECT 1")
+			assert.Errorf(t, err, "test dsn is supposed to fail: %s", tc.DSN)
+
+			if err == nil {
+				require.NoError(t, db.Close())
+			}
+		})
+	}
+}
+
+func TestRegisterCustomClientReserved(t *testing.T) {
+	for _, tc := range []string{"true", "false"} {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			require.Errorf(t,
+				RegisterCustomClient(tc, &http.Client{}),
+				"client key name supposed to fail: %s", tc)
+		})
+	}
+}
+
+func TestRoundTripRetryQueryError(t *testing.T) {
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count == 0 {
+			count++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&stmtResponse{
+			Error: ErrTrino{
+				ErrorName: "TEST",
+			},
+		})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Query("SELECT 1")
+	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
+}
+
+func TestLogger(t *testing.T) {
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count == 0 {
+			count++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if count == 1 {
+			count++
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	var buf bytes.Buffer
+	configLogger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	connector, err := NewConnector(&Config{ServerURI: ts.URL, Logger: configLogger}, WithLogger(logger))
+	require.NoError(t, err)
+
+	db := sql.OpenDB(connector)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT 1")
+	require.Error(t, err)
+
+	logs := buf.String()
+	assert.Contains(t, logs, `level=DEBUG msg="trino: sending request" method=POST`)
+	assert.Contains(t, logs, `level=DEBUG msg="trino: server unavailable, retrying request"`)
+	assert.Contains(t, logs, `level=DEBUG msg="trino: received response" method=POST url=`+ts.URL+`/v1/statement status=200`)
+	assert.Contains(t, logs, `level=ERROR msg="trino: query failed"`)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestMiddleware(t *testing.T) {
+	var headers []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Values("X-Middleware")
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	middleware := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Middleware", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	connector, err := NewConnector(&Config{
+		ServerURI:  ts.URL,
+		Middleware: []func(http.RoundTripper) http.RoundTripper{middleware("outer"), middleware("inner")},
+	})
+	require.NoError(t, err)
+
+	db := sql.OpenDB(connector)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner"}, headers)
+}
+
+func TestDebugTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "response")
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	limit := DebugBodyLimit
+	DebugBodyLimit = 10
+	t.Cleanup(func() { DebugBodyLimit = limit })
+
+	var buf bytes.Buffer
+	client := &http.Client{Transport: NewDebugTransport(nil, &buf)}
+	require.NoError(t, RegisterCustomClient("debug", client))
+	t.Cleanup(func() { DeregisterCustomClient("debug") })
+
+	db, err := sql.Open("trino", ts.URL+"?custom_client=debug&extra_credentials=token:secret")
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "> POST "+ts.URL+"/v1/statement\n")
+	assert.Contains(t, out, "> X-Trino-Extra-Credential: <redacted>\n")
+	assert.NotContains(t, out, "secret")
+	assert.Contains(t, out, "< HTTP/1.1 200 OK "+ts.URL+"/v1/statement in ")
+	assert.Contains(t, out, "< X-Test: response\n")
+	assert.Contains(t, out, "<\n{\"id\":\"fak\n")
+}
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	events   []string
+	segments []int64
+	rowsRead int64
+	err      error
+}
+
+func (m *recordingMetrics) OnQueryStart(queryID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, "start "+queryID)
+}
+
+func (m *recordingMetrics) OnQueryEnd(queryID string, duration time.Duration, rowsRead int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, "end "+queryID)
+	m.rowsRead = rowsRead
+	m.err = err
+}
+
+func (m *recordingMetrics) OnSegmentDownload(queryID string, segmentType string, bytes int64, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, segmentType+" segment "+queryID)
+	m.segments = append(m.segments, bytes)
+}
+
+func TestConnMetrics(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "FAIL") {
+				json.NewEncoder(w).Encode(&stmtResponse{ID: "failed-query", Error: ErrTrino{ErrorName: "TEST"}})
+				return
+			}
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		columns := []queryColumn{{Name: "a", Type: "bigint", TypeSignature: typeSignature{RawType: "bigint"}}}
+		if r.URL.Path == "/v1/statement/20210817_140827_00000_arvdv/1" {
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/2",
+				Columns: columns,
+				Data:    []queryData{{json.Number("1")}, {json.Number("2")}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "fake-query",
+			Columns: columns,
+			Data:    []queryData{{json.Number("3")}},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	metrics := &recordingMetrics{}
+	connector, err := NewConnector(&Config{ServerURI: ts.URL, ConnMetrics: metrics})
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	rows, err := db.Query("SELECT a FROM t")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	metrics.mu.Lock()
+	assert.Equal(t, []string{
+		"start fake-query",
+		"inline segment fake-query",
+		"inline segment fake-query",
+		"inline segment fake-query",
+		"end fake-query",
+	}, metrics.events)
+	assert.Equal(t, int64(3), metrics.rowsRead)
+	assert.NoError(t, metrics.err)
+	for _, n := range metrics.segments {
+		assert.Greater(t, n, int64(0))
+	}
+	metrics.events = nil
+	metrics.mu.Unlock()
+
+	_, err = db.Exec("SELECT FAIL")
+	require.Error(t, err)
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	assert.Equal(t, []string{"start failed-query", "inline segment failed-query", "end failed-query"}, metrics.events)
+	assert.Error(t, metrics.err)
+}
+
+func TestNewConnectorInvalidConfig(t *testing.T) {
+	_, err := NewConnector(&Config{ServerURI: "http://foobar@localhost:8080", Schema: "test"})
+	assert.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := Open(&Config{
+		ServerURI:       ts.URL,
+		MaxIdleConns:    1,
+		MaxOpenConns:    3,
+		ConnMaxLifetime: time.Minute,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	require.NoError(t, db.Ping())
+	assert.Equal(t, 3, db.Stats().MaxOpenConnections)
+
+	_, err = Open(&Config{ServerURI: ts.URL, Schema: "test"})
+	assert.Error(t, err, "invalid configuration")
+
+	_, err = Open(&Config{ServerURI: ts.URL, MaxOpenConns: -1})
+	assert.Error(t, err, "negative pool limit")
+}
+
+func TestOpenPrewarmConnections(t *testing.T) {
+	const n = 3
+	var requests sync.WaitGroup
+	requests.Add(n)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only reply once all the connections were opened, to check that they are used concurrently
+		requests.Done()
+		done := make(chan struct{})
+		go func() {
+			requests.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := Open(&Config{
+		ServerURI:          ts.URL,
+		MaxIdleConns:       n,
+		PrewarmConnections: n,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	assert.Eventually(t, func() bool {
+		stats := db.Stats()
+		return stats.OpenConnections == n && stats.Idle == n
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = Open(&Config{ServerURI: ts.URL, PrewarmConnections: -1})
+	assert.Error(t, err, "negative prewarm connections")
+}
+
+func TestOpenPrewarmConnectionsTimeout(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server only notices the client going away once the body is read
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := Open(&Config{
+		ServerURI:          ts.URL,
+		ConnectTimeout:     100 * time.Millisecond,
+		PrewarmConnections: 1,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prewarm ping not cancelled after the connect timeout")
+	}
+}
+
+func TestExplainQuery(t *testing.T) {
+	var queries []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			queries = append(queries, string(body))
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "fake-query",
+			Columns: []queryColumn{{Name: "Query Plan", Type: "varchar", TypeSignature: typeSignature{RawType: "varchar"}}},
+			Data:    []queryData{{"Fragment 0 [SINGLE]"}, {"    Output[columnNames = [name]]"}},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	ctx := context.Background()
+	plan, err := ExplainQuery(ctx, db, "SELECT name FROM nation WHERE regionkey = ? AND comment <> '?'", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Fragment 0 [SINGLE]\n    Output[columnNames = [name]]", plan)
+
+	_, err = ExplainAnalyze(ctx, db, "SELECT name FROM nation WHERE name = ?", "BRAZIL")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"EXPLAIN SELECT name FROM nation WHERE regionkey = 1 AND comment <> '?'",
+		"EXPLAIN ANALYZE SELECT name FROM nation WHERE name = 'BRAZIL'",
+	}, queries)
+}
+
+func TestCopyAsJSON(t *testing.T) {
+	var queries []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			queries = append(queries, string(body))
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		columns := []queryColumn{
+			{Name: "id", Type: "bigint", TypeSignature: typeSignature{RawType: "bigint"}},
+			{Name: "name", Type: "varchar", TypeSignature: typeSignature{RawType: "varchar"}},
+			{Name: "price", Type: "decimal(10,2)", TypeSignature: typeSignature{RawType: "decimal"}},
+			{Name: "tags", Type: "array(varchar)", TypeSignature: typeSignature{RawType: "array", Arguments: []typeArgument{{Kind: KIND_TYPE, typeSignature: typeSignature{RawType: "varchar"}}}}},
+		}
+		if r.URL.Path == "/v1/statement/20210817_140827_00000_arvdv/1" {
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/2",
+				Columns: columns,
+				Data:    []queryData{{json.Number("1"), "<a & b>", "12.50", []interface{}{"x", "y"}}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "fake-query",
+			Columns: columns,
+			Data:    []queryData{{json.Number("12345678901234567890"), nil, "0.10", []interface{}{}}},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	var buf bytes.Buffer
+	n, err := CopyAsJSON(context.Background(), &buf, db, "SELECT * FROM products WHERE id > ?", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, `{"id":1,"name":"<a & b>","price":"12.50","tags":["x","y"]}
+{"id":12345678901234567890,"name":null,"price":"0.10","tags":[]}
+`, buf.String())
+	assert.Equal(t, []string{"EXECUTE IMMEDIATE 'SELECT * FROM products WHERE id > ?' USING 0"}, queries)
+
+	_, err = CopyAsJSON(context.Background(), &buf, db, "SELECT ?", 1.5)
+	assert.Error(t, err, "unsupported argument")
+}
+
+func TestRowsExhausted(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			if string(body) == "CREATE TABLE t (a bigint)" {
+				json.NewEncoder(w).Encode(&stmtResponse{
+					ID:      "fake-query",
+					NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/3",
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		columns := []queryColumn{{Name: "a", Type: "bigint", TypeSignature: typeSignature{RawType: "bigint"}}}
+		switch r.URL.Path {
+		case "/v1/statement/20210817_140827_00000_arvdv/1":
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/2",
+				Columns: columns,
+				Data:    []queryData{{json.Number("1")}},
+			})
+		case "/v1/statement/20210817_140827_00000_arvdv/2":
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:      "fake-query",
+				Columns: columns,
+				Data:    []queryData{{json.Number("2")}},
+			})
+		default:
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:         "fake-query",
+				UpdateType: "CREATE TABLE",
+			})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	rows, err := db.Query("SELECT a FROM t")
+	require.NoError(t, err)
+	var values []int64
+	for rows.Next() {
+		var v int64
+		require.NoError(t, rows.Scan(&v))
+		values = append(values, v)
+	}
+	assert.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, []int64{1, 2}, values)
+
+	rows, err = db.Query("CREATE TABLE t (a bigint)")
+	require.NoError(t, err)
+	assert.False(t, rows.Next())
+	assert.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, conn.Close()) })
+	for _, query := range []string{"SELECT a FROM t", "CREATE TABLE t (a bigint)"} {
+		err = conn.Raw(func(driverConn interface{}) error {
+			st, err := driverConn.(*Conn).PrepareContext(context.Background(), query)
+			require.NoError(t, err)
+			defer st.Close()
+			rows, err := st.(*driverStmt).QueryContext(context.Background(), nil)
+			require.NoError(t, err)
+			defer rows.Close()
+			dest := make([]driver.Value, len(rows.Columns()))
+			for rows.Next(dest) == nil {
+			}
+			assert.Equal(t, io.EOF, rows.Next(dest), query)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestInterpolateArgs(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		args     []interface{}
+		expected string
+	}{
+		{"SELECT 1", nil, "SELECT 1"},
+		{"SELECT ?, ?", []interface{}{1, "it's"}, "SELECT 1, 'it''s'"},
+		{`SELECT 'a''?', "b""?", ?`, []interface{}{true}, `SELECT 'a''?', "b""?", true`},
+		{"SELECT ? -- what?\nFROM t", []interface{}{1}, "SELECT 1 -- what?\nFROM t"},
+		{"SELECT ? -- what?", []interface{}{1}, "SELECT 1 -- what?"},
+		{"SELECT /* ? */ ?", []interface{}{Date(2024, 1, 2)}, "SELECT /* ? */ DATE '2024-01-02'"},
+	} {
+		actual, err := interpolateArgs(tc.query, tc.args)
+		require.NoError(t, err, tc.query)
+		assert.Equal(t, tc.expected, actual)
+	}
+
+	for name, tc := range map[string]struct {
+		query string
+		args  []interface{}
+	}{
+		"too few arguments":    {"SELECT ?, ?", []interface{}{1}},
+		"too many arguments":   {"SELECT ?", []interface{}{1, 2}},
+		"unsupported argument": {"SELECT ?", []interface{}{1.5}},
+		"named argument":       {"SELECT ?", []interface{}{sql.Named("a", 1)}},
+		"unterminated string":  {"SELECT 'a", nil},
+		"unterminated comment": {"SELECT /* a", nil},
+	} {
+		_, err := interpolateArgs(tc.query, tc.args)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestQueryBuilder(t *testing.T) {
+	query, args, err := NewQueryBuilder().
+		Select("orderkey", "totalprice").
+		From("tpch.tiny.orders").
+		Where("orderstatus", "=", "F").
+		Where("orderdate", ">=", Date(1995, 1, 1)).
+		Where("comment", "not like", "%special%").
+		Limit(10).
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT orderkey, totalprice FROM tpch.tiny.orders WHERE orderstatus = ? AND orderdate >= ? AND comment NOT LIKE ? LIMIT 10", query)
+	assert.Equal(t, []interface{}{"F", Date(1995, 1, 1), "%special%"}, args)
+
+	query, args, err = NewQueryBuilder().From("tpch.tiny.nation").Build()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM tpch.tiny.nation", query)
+	assert.Empty(t, args)
+
+	for name, b := range map[string]*QueryBuilder{
+		"no table":          NewQueryBuilder().Select("a"),
+		"invalid operator":  NewQueryBuilder().From("t").Where("a", "; DROP TABLE t --", 1),
+		"unsupported value": NewQueryBuilder().From("t").Where("a", "=", 1.5),
+		"negative limit":    NewQueryBuilder().From("t").Limit(-1),
+	} {
+		_, _, err := b.Build()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var unavailable bool
+	var ts *httptest.Server
+	ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// flush responses, so they are sent without a content length
+		defer w.(http.Flusher).Flush()
+		if unavailable = !unavailable; unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("retry later"))
+			return
+		}
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{ID: "fake-query"})
+	}))
+	var connections atomic.Int32
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	for i := 0; i < 3; i++ {
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), connections.Load())
+}
+
+func TestRedirect(t *testing.T) {
+	var queries []string
+	var coordinator *httptest.Server
+	coordinator = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			queries = append(queries, r.Method+" "+string(body))
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: coordinator.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "fake-query",
+			Columns: []queryColumn{{Name: "_col0", Type: "integer", TypeSignature: typeSignature{RawType: "integer"}}},
+			Data:    []queryData{{json.Number("1")}},
+		})
+	}))
+	t.Cleanup(coordinator.Close)
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, coordinator.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(redirecting.Close)
+
+	db, err := sql.Open("trino", redirecting.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	rows, err := db.Query("SELECT 1")
+	require.NoError(t, err)
+	var values []int
+	for rows.Next() {
+		var value int
+		require.NoError(t, rows.Scan(&value))
+		values = append(values, value)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{1}, values)
+	assert.Equal(t, []string{"POST SELECT 1"}, queries)
+}
+
+func TestRoundTripBogusData(t *testing.T) {
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count == 0 {
+			count++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		// some invalid JSON
+		w.Write([]byte(`{"stats": {"progressPercentage": ""}}`))
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	rows, err := db.Query("SELECT 1")
+	require.NoError(t, err)
+	assert.False(t, rows.Next())
+	require.NoError(t, rows.Err())
+}
+
+func TestRoundTripCancellation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	t.Cleanup(cancel)
+
+	_, err = db.QueryContext(ctx, "SELECT 1")
+	assert.Error(t, err, "unexpected query with cancelled context succeeded")
+}
+
+func TestAuthFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	assert.NoError(t, db.Close())
+}
+
+func TestTokenAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?accessToken=token")
+	require.NoError(t, err)
+
+	_, err = db.Query("SELECT 1")
+	require.Error(t, err, "trino: EOF")
+
+	assert.NoError(t, db.Close())
+}
+
+func TestQueryForUsername(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode.")
+	}
+	c := &Config{
+		ServerURI:         *integrationServerFlag,
+		SessionProperties: map[string]string{"query_priority": "1"},
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	rows, err := db.Query("SELECT current_user", sql.Named("X-Trino-User", string("TestUser")))
+	require.NoError(t, err, "Failed executing query")
+	assert.NotNil(t, rows)
+
+	for rows.Next() {
+		var user string
+		require.NoError(t, rows.Scan(&user), "Failed scanning query result")
+
+		assert.Equal(t, "TestUser", user, "Expected value does not equal result value")
+	}
+}
+
+type TestQueryProgressCallback struct {
+	progressMap map[time.Time]float64
+	statusMap   map[time.Time]string
+	queryIDs    []string
+}
+
+func (qpc *TestQueryProgressCallback) Update(qpi QueryProgressInfo) {
+	qpc.progressMap[time.Now()] = float64(qpi.QueryStats.ProgressPercentage)
+	qpc.statusMap[time.Now()] = qpi.QueryStats.State
+	qpc.queryIDs = append(qpc.queryIDs, qpi.QueryID)
+}
+
+func TestQueryProgressQueryID(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "20210817_140827_00000_arvdv",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+				Stats:   stmtStats{State: "QUEUED"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "20210817_140827_00000_arvdv",
+			Columns: []queryColumn{{Name: "_col0", Type: "integer", TypeSignature: typeSignature{RawType: "integer"}}},
+			Data:    []queryData{{json.Number("2")}},
+			Stats:   stmtStats{State: "FINISHED"},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	callback := &TestQueryProgressCallback{
+		progressMap: make(map[time.Time]float64),
+		statusMap:   make(map[time.Time]string),
+	}
+	rows, err := db.Query("SELECT 2",
+		sql.Named(trinoProgressCallbackParam, callback),
+		sql.Named(trinoProgressCallbackPeriodParam, time.Millisecond),
+	)
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	require.NotEmpty(t, callback.queryIDs)
+	for _, id := range callback.queryIDs {
+		assert.Equal(t, "20210817_140827_00000_arvdv", id)
+	}
+}
+
+func TestQueryProgressWithCallback(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode.")
+	}
+	c := &Config{
+		ServerURI:         *integrationServerFlag,
+		SessionProperties: map[string]string{"query_priority": "1"},
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	callback := &TestQueryProgressCallback{}
+
+	_, err = db.Query("SELECT 2", sql.Named("X-Trino-Progress-Callback", callback))
+	assert.EqualError(t, err, ErrInvalidProgressCallbackHeader.Error(), "unexpected error")
+}
+
+func TestQueryProgressWithCallbackPeriod(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode.")
+	}
+	c := &Config{
+		ServerURI:         *integrationServerFlag,
+		SessionProperties: map[string]string{"query_priority": "1"},
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	progressMap := make(map[time.Time]float64)
+	statusMap := make(map[time.Time]string)
+	progressUpdater := &TestQueryProgressCallback{
+		progressMap: progressMap,
+		statusMap:   statusMap,
+	}
+	progressUpdaterPeriod, err := time.ParseDuration("1ms")
+	require.NoError(t, err)
+
+	rows, err := db.Query("SELECT 2",
+		sql.Named("X-Trino-Progress-Callback", progressUpdater),
+		sql.Named("X-Trino-Progress-Callback-Period", progressUpdaterPeriod),
+	)
+	require.NoError(t, err, "Failed executing query")
+	assert.NotNil(t, rows)
+
+	for rows.Next() {
+		var ts string
+		require.NoError(t, rows.Scan(&ts), "Failed scanning query result")
+
+		assert.Equal(t, "2", ts, "Expected value does not equal result value")
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err = rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// sort time in order to calculate interval
+	assert.NotEmpty(t, progressMap)
+	assert.NotEmpty(t, statusMap)
+	var keys []time.Time
+	for k := range statusMap {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Before(keys[j])
+	})
+
+	for i, k := range keys {
+		if i > 0 {
+			assert.GreaterOrEqual(t, k.Sub(keys[i-1]), progressUpdaterPeriod)
+		}
+		assert.GreaterOrEqual(t, progressMap[k], 0.0)
+	}
+}
+
+func TestErrTrinoFailureChain(t *testing.T) {
+	root := &FailureInfo{Type: "java.io.IOException", Message: "disk full"}
+	middle := &FailureInfo{Type: "io.trino.spi.TrinoException", Message: "write failed", Cause: root}
+	err := &ErrTrino{
+		Message:     "query failed",
+		FailureInfo: FailureInfo{Type: "io.trino.spi.TrinoException", Message: "query failed", Cause: middle},
+	}
+
+	chain := err.FailureChain()
+	require.Len(t, chain, 3)
+	assert.Same(t, &err.FailureInfo, chain[0])
+	assert.Same(t, middle, chain[1])
+	assert.Same(t, root, chain[2])
+	assert.Same(t, root, err.RootCause())
+
+	single := &ErrTrino{FailureInfo: FailureInfo{Type: "io.trino.spi.TrinoException"}}
+	assert.Len(t, single.FailureChain(), 1)
+	assert.Same(t, &single.FailureInfo, single.RootCause())
+
+	empty := &ErrTrino{Message: "no failure info"}
+	assert.Nil(t, empty.FailureChain())
+	assert.Nil(t, empty.RootCause())
+}
+
+func TestErrTrinoIs(t *testing.T) {
+	var err error = &ErrQueryFailed{
+		StatusCode: http.StatusOK,
+		Reason:     &ErrTrino{Message: "catalog 'foo' not found", ErrorName: "CATALOG_NOT_FOUND", ErrorType: "USER_ERROR"},
+	}
+
+	assert.ErrorIs(t, err, TrinoErrorByName("CATALOG_NOT_FOUND"))
+	assert.ErrorIs(t, err, &ErrTrino{ErrorName: "CATALOG_NOT_FOUND"})
+	assert.NotErrorIs(t, err, TrinoErrorByName("SCHEMA_NOT_FOUND"))
+	assert.NotErrorIs(t, err, (*ErrTrino)(nil))
+	assert.NotErrorIs(t, err, ErrQueryCancelled)
+}
+
+func TestErrQueryFailedHTTPStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	_, err = db.Exec("SELECT 1")
+	var qferr *ErrQueryFailed
+	require.ErrorAs(t, err, &qferr)
+	assert.Equal(t, http.StatusTooManyRequests, qferr.HTTPStatus())
+
+	// failures without a response have no status
+	ts.Close()
+	_, err = db.Exec("SELECT 1")
+	require.ErrorAs(t, err, &qferr)
+	assert.Equal(t, 0, qferr.HTTPStatus())
+}
+
+func TestQueryStatsDurations(t *testing.T) {
+	var stats stmtStats
+	require.NoError(t, json.Unmarshal([]byte(`{"elapsedTimeMillis": 1500, "queuedTimeMillis": 20, "cpuTimeMillis": 300, "wallTimeMillis": 4000}`), &stats))
+
+	assert.Equal(t, 1500*time.Millisecond, stats.ElapsedTime())
+	assert.Equal(t, 20*time.Millisecond, stats.QueuedTime())
+	assert.Equal(t, 300*time.Millisecond, stats.CPUTime())
+	assert.Equal(t, 4*time.Second, stats.WallTime())
+
+	assert.Zero(t, stmtStats{}.ElapsedTime())
+}
+
+func TestQueryColumns(t *testing.T) {
+	c := &Config{
+		ServerURI:         *integrationServerFlag,
+		SessionProperties: map[string]string{"query_priority": "1"},
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	rows, err := db.Query(`SELECT
+  true AS bool,
+  cast(123 AS tinyint) AS tinyint,
+  cast(456 AS smallint) AS smallint,
+  cast(678 AS integer) AS integer,
+  cast(1234 AS bigint) AS bigint,
+  cast(1.23 AS real) AS real,
+  cast(1.23 AS double) AS double,
+  cast(1.23 as decimal(10,5)) AS decimal,
+  cast('aaa' as varchar) AS vunbounded,
+  cast('bbb' as varchar(10)) AS vbounded,
+  cast('ccc' AS char) AS cunbounded,
+  cast('ddd' as char(10)) AS cbounded,
+  cast('ddd' as varbinary) AS varbinary,
+  cast('{"aaa": 1}' as json) AS json,
+  current_date AS date,
+  cast(current_time as time) AS time,
+  cast(current_time as time(6)) AS timep,
+  cast(current_time as time with time zone) AS timetz,
+  cast(current_time as timestamp) AS ts,
+  cast(current_time as timestamp(6)) AS tsp,
+  cast(current_time as timestamp with time zone) AS tstz,
+  cast(current_time as timestamp(6) with time zone) AS tsptz,
+  interval '3' month AS ytm,
+  interval '2' day AS dts,
+  array['a', 'b'] AS varray,
+  array[array['a'], array['b']] AS v2array,
+  array[array[array['a'], array['b']]] AS v3array,
+  map(array['a'], array[1]) AS map,
+  array[map(array['a'], array[1]), map(array['b'], array[2])] AS marray,
+  row('a', 1) AS row,
+  cast(row('a', 1.23) AS row(x varchar, y double)) AS named_row,
+  ipaddress '10.0.0.1' AS ip,
+  uuid '12151fd2-7586-11e9-8f9e-2a86e4085a59' AS uuid`)
+	require.NoError(t, err, "Failed executing query")
+	assert.NotNil(t, rows)
+
+	columns, err := rows.Columns()
+	require.NoError(t, err, "Failed reading result columns")
+
+	assert.Equal(t, 33, len(columns), "Expected 33 result column")
+	expectedNames := []string{
+		"bool",
+		"tinyint",
+		"smallint",
+		"integer",
+		"bigint",
+		"real",
+		"double",
+		"decimal",
+		"vunbounded",
+		"vbounded",
+		"cunbounded",
+		"cbounded",
+		"varbinary",
+		"json",
+		"date",
+		"time",
+		"timep",
+		"timetz",
+		"ts",
+		"tsp",
+		"tstz",
+		"tsptz",
+		"ytm",
+		"dts",
+		"varray",
+		"v2array",
+		"v3array",
+		"map",
+		"marray",
+		"row",
+		"named_row",
+		"ip",
+		"uuid",
+	}
+	assert.Equal(t, expectedNames, columns)
+
+	columnTypes, err := rows.ColumnTypes()
+	require.NoError(t, err, "Failed reading result column types")
+
+	assert.Equal(t, 33, len(columnTypes), "Expected 33 result column type")
+
+	type columnType struct {
+		typeName  string
+		hasScale  bool
+		precision int64
+		scale     int64
+		hasLength bool
+		length    int64
+		scanType  reflect.Type
+	}
+	expectedTypes := []columnType{
+		{
+			"BOOLEAN",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullBool{}),
+		},
+		{
+			"TINYINT",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullInt32{}),
+		},
+		{
+			"SMALLINT",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullInt32{}),
+		},
+		{
+			"INTEGER",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullInt32{}),
+		},
+		{
+			"BIGINT",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullInt64{}),
+		},
+		{
+			"REAL",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullFloat64{}),
+		},
+		{
+			"DOUBLE",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(sql.NullFloat64{}),
+		},
+		{
+			"DECIMAL(10,5)",
+			true,
+			10,
+			5,
+			false,
+			0,
+			reflect.TypeOf(sql.NullString{}),
+		},
+		{
+			"VARCHAR",
+			false,
+			0,
+			0,
+			true,
+			math.MaxInt32,
+			reflect.TypeOf(sql.NullString{}),
+		},
+		{
+			"VARCHAR(10)",
+			false,
+			0,
+			0,
+			true,
+			10,
+			reflect.TypeOf(sql.NullString{}),
+		},
+		{
+			"CHAR(1)",
+			false,
+			0,
+			0,
+			true,
+			1,
+			reflect.TypeOf(sql.NullString{}),
+		},
+		{
+			"CHAR(10)",
+			false,
+			0,
+			0,
+			true,
+			10,
+			reflect.TypeOf(sql.NullString{}),
 		},
 		{
 			"VARBINARY",
@@ -755,7 +2434,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIME",
+			"TIME(3)",
 			true,
 			3,
 			0,
@@ -764,7 +2443,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIME",
+			"TIME(6)",
 			true,
 			6,
 			0,
@@ -773,7 +2452,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIME WITH TIME ZONE",
+			"TIME(3) WITH TIME ZONE",
 			true,
 			3,
 			0,
@@ -782,7 +2461,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIMESTAMP",
+			"TIMESTAMP(3)",
 			true,
 			3,
 			0,
@@ -791,7 +2470,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIMESTAMP",
+			"TIMESTAMP(6)",
 			true,
 			6,
 			0,
@@ -800,7 +2479,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIMESTAMP WITH TIME ZONE",
+			"TIMESTAMP(3) WITH TIME ZONE",
 			true,
 			3,
 			0,
@@ -809,7 +2488,7 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"TIMESTAMP WITH TIME ZONE",
+			"TIMESTAMP(6) WITH TIME ZONE",
 			true,
 			6,
 			0,
@@ -881,164 +2560,1333 @@ func TestQueryColumns(t *testing.T) {
 			reflect.TypeOf(NullSliceMap{}),
 		},
 		{
-			"ROW(VARCHAR(1), INTEGER)",
-			false,
-			0,
+			"ROW(VARCHAR(1), INTEGER)",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(new(interface{})).Elem(),
+		},
+		{
+			"ROW(x VARCHAR, y DOUBLE)",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(new(interface{})).Elem(),
+		},
+		{
+			"IPADDRESS",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(NullIP{}),
+		},
+		{
+			"UUID",
+			false,
+			0,
+			0,
+			false,
+			0,
+			reflect.TypeOf(NullUUID{}),
+		},
+	}
+	actualTypes := make([]columnType, 33)
+	for i, column := range columnTypes {
+		actualTypes[i].typeName = column.DatabaseTypeName()
+		actualTypes[i].precision, actualTypes[i].scale, actualTypes[i].hasScale = column.DecimalSize()
+		actualTypes[i].length, actualTypes[i].hasLength = column.Length()
+		actualTypes[i].scanType = column.ScanType()
+	}
+
+	assert.Equal(t, actualTypes, expectedTypes)
+}
+
+func TestColumnTypeDatabaseTypeName(t *testing.T) {
+	long := func(v int64) typeArgument {
+		return typeArgument{Kind: KIND_LONG, long: v}
+	}
+	testcases := []struct {
+		typeName  string
+		signature typeSignature
+		expected  string
+	}{
+		{"decimal(10,5)", typeSignature{RawType: "decimal", Arguments: []typeArgument{long(10), long(5)}}, "DECIMAL(10,5)"},
+		{"varchar(255)", typeSignature{RawType: "varchar", Arguments: []typeArgument{long(255)}}, "VARCHAR(255)"},
+		{"char(10)", typeSignature{RawType: "char", Arguments: []typeArgument{long(10)}}, "CHAR(10)"},
+		{"timestamp(6)", typeSignature{RawType: "timestamp", Arguments: []typeArgument{long(6)}}, "TIMESTAMP(6)"},
+		{"timestamp(6) with time zone", typeSignature{RawType: "timestamp with time zone", Arguments: []typeArgument{long(6)}}, "TIMESTAMP(6) WITH TIME ZONE"},
+		{"bigint", typeSignature{RawType: "bigint"}, "BIGINT"},
+	}
+	for _, tc := range testcases {
+		converter, err := newTypeConverter(tc.typeName, tc.signature)
+		require.NoError(t, err)
+		rows := &driverRows{coltype: []*typeConverter{converter}}
+		assert.Equal(t, tc.expected, rows.ColumnTypeDatabaseTypeName(0))
+	}
+
+	for typeName, expected := range map[string]string{
+		"row(x integer, Label varchar(1))":                         "ROW(x INTEGER, Label VARCHAR(1))",
+		`row("first name" varchar, "a ""b"" c" bigint)`:            `ROW("first name" VARCHAR, "a ""b"" c" BIGINT)`,
+		"array(row(createdAt timestamp(3) with time zone))":        "ARRAY(ROW(createdAt TIMESTAMP(3) WITH TIME ZONE))",
+		"row(time with time zone, interval day to second)":         "ROW(TIME WITH TIME ZONE, INTERVAL DAY TO SECOND)",
+		"row(time time(3), timestamp timestamp(6) with time zone)": "ROW(time TIME(3), timestamp TIMESTAMP(6) WITH TIME ZONE)",
+		"map(varchar, row(key integer))":                           "MAP(VARCHAR, ROW(key INTEGER))",
+	} {
+		assert.Equal(t, expected, upperTypeKeywords(typeName))
+	}
+}
+
+func TestIntegerArrayScanType(t *testing.T) {
+	array := func(element typeSignature) typeSignature {
+		return typeSignature{RawType: "array", Arguments: []typeArgument{{Kind: KIND_TYPE, typeSignature: element}}}
+	}
+	integer := typeSignature{RawType: "integer"}
+	bigint := typeSignature{RawType: "bigint"}
+	for _, tc := range []struct {
+		typeName  string
+		signature typeSignature
+		expected  reflect.Type
+	}{
+		{"array(integer)", array(integer), reflect.TypeOf(NullSliceInt32{})},
+		{"array(array(integer))", array(array(integer)), reflect.TypeOf(NullSlice2Int32{})},
+		{"array(array(array(integer)))", array(array(array(integer))), reflect.TypeOf(NullSlice3Int32{})},
+		{"array(smallint)", array(typeSignature{RawType: "smallint"}), reflect.TypeOf(NullSliceInt32{})},
+		{"array(bigint)", array(bigint), reflect.TypeOf(NullSliceInt64{})},
+		{"array(array(bigint))", array(array(bigint)), reflect.TypeOf(NullSlice2Int64{})},
+	} {
+		converter, err := newTypeConverter(tc.typeName, tc.signature)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, converter.scanType, tc.typeName)
+	}
+
+	var s NullSliceInt32
+	require.NoError(t, s.Scan([]interface{}{json.Number("1"), nil}))
+	assert.Equal(t, []sql.NullInt32{{Int32: 1, Valid: true}, {}}, s.SliceInt32)
+	assert.Error(t, s.Scan([]interface{}{json.Number("2147483648")}), "out of range")
+}
+
+func TestColumnTypeDecimalSizeTime(t *testing.T) {
+	long := func(v int64) []typeArgument {
+		return []typeArgument{{Kind: KIND_LONG, Value: json.RawMessage(strconv.FormatInt(v, 10))}}
+	}
+	columns := []queryColumn{
+		{Name: "a", Type: "time(6)", TypeSignature: typeSignature{RawType: "time", Arguments: long(6)}},
+		{Name: "b", Type: "timestamp(6)", TypeSignature: typeSignature{RawType: "timestamp", Arguments: long(6)}},
+		{Name: "c", Type: "timestamp(3) with time zone", TypeSignature: typeSignature{RawType: "timestamp with time zone", Arguments: long(3)}},
+		{Name: "d", Type: "time(9) with time zone", TypeSignature: typeSignature{RawType: "time with time zone", Arguments: long(9)}},
+		{Name: "e", Type: "date", TypeSignature: typeSignature{RawType: "date"}},
+	}
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{ID: "fake-query", Columns: columns})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	rows, err := db.Query("SELECT a, b, c, d, e FROM t")
+	require.NoError(t, err)
+	columnTypes, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	require.Len(t, columnTypes, len(columns))
+
+	expected := []struct {
+		precision int64
+		ok        bool
+	}{{6, true}, {6, true}, {3, true}, {9, true}, {0, false}}
+	for i, columnType := range columnTypes {
+		precision, scale, ok := columnType.DecimalSize()
+		assert.Equal(t, expected[i].ok, ok, columnType.DatabaseTypeName())
+		assert.Equal(t, expected[i].precision, precision, columnType.DatabaseTypeName())
+		assert.Zero(t, scale, columnType.DatabaseTypeName())
+	}
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+}
+
+func TestColumnTypeNullable(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID: "fake-query",
+			Columns: []queryColumn{
+				{Name: "a", Type: "bigint", TypeSignature: typeSignature{RawType: "bigint"}},
+				{Name: "b", Type: "varchar", TypeSignature: typeSignature{RawType: "varchar"}},
+			},
+			Data: []queryData{{json.Number("1"), nil}},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	rows, err := db.Query("SELECT 1 AS a, CAST(NULL AS varchar) AS b")
+	require.NoError(t, err)
+	columnTypes, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	require.Len(t, columnTypes, 2)
+	for _, columnType := range columnTypes {
+		nullable, ok := columnType.Nullable()
+		assert.True(t, ok, columnType.Name())
+		assert.True(t, nullable, columnType.Name())
+	}
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+}
+
+func TestColumnTypeLength(t *testing.T) {
+	long := func(v int64) typeArgument {
+		return typeArgument{Kind: KIND_LONG, long: v}
+	}
+	testcases := []struct {
+		typeName       string
+		signature      typeSignature
+		expectedLength int64
+		expectedOk     bool
+	}{
+		{"char", typeSignature{RawType: "char"}, 1, true},
+		{"char(10)", typeSignature{RawType: "char", Arguments: []typeArgument{long(10)}}, 10, true},
+		{"varchar(255)", typeSignature{RawType: "varchar", Arguments: []typeArgument{long(255)}}, 255, true},
+		{"varchar", typeSignature{RawType: "varchar", Arguments: []typeArgument{long(math.MaxInt32)}}, math.MaxInt32, true},
+		{"bigint", typeSignature{RawType: "bigint"}, 0, false},
+	}
+	for _, tc := range testcases {
+		converter, err := newTypeConverter(tc.typeName, tc.signature)
+		require.NoError(t, err)
+		rows := &driverRows{coltype: []*typeConverter{converter}}
+		length, ok := rows.ColumnTypeLength(0)
+		assert.Equal(t, tc.expectedLength, length, tc.typeName)
+		assert.Equal(t, tc.expectedOk, ok, tc.typeName)
+	}
+}
+
+func TestMaxGoPrecisionDateTime(t *testing.T) {
+	c := &Config{
+		ServerURI:         *integrationServerFlag,
+		SessionProperties: map[string]string{"query_priority": "1"},
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	rows, err := db.Query(`SELECT
+  cast(current_time as time(9)) AS timep,
+  cast(current_time as time(9) with time zone) AS timeptz,
+  cast(current_time as timestamp(9)) AS tsp,
+  cast(current_time as timestamp(9) with time zone) AS tsptz`)
+	require.NoError(t, err, "Failed executing query")
+	assert.NotNil(t, rows)
+
+	columns, err := rows.Columns()
+	require.NoError(t, err, "Failed reading result columns")
+
+	assert.Equal(t, 4, len(columns), "Expected 4 result column")
+	expectedNames := []string{
+		"timep",
+		"timeptz",
+		"tsp",
+		"tsptz",
+	}
+	assert.Equal(t, expectedNames, columns)
+
+	columnTypes, err := rows.ColumnTypes()
+	require.NoError(t, err, "Failed reading result column types")
+
+	assert.Equal(t, 4, len(columnTypes), "Expected 4 result column type")
+
+	type columnType struct {
+		typeName  string
+		hasScale  bool
+		precision int64
+		scale     int64
+		hasLength bool
+		length    int64
+		scanType  reflect.Type
+	}
+	expectedTypes := []columnType{
+		{
+			"TIME",
+			true,
+			9,
 			0,
 			false,
 			0,
-			reflect.TypeOf(new(interface{})).Elem(),
+			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"ROW(X VARCHAR, Y DOUBLE)",
-			false,
-			0,
+			"TIME WITH TIME ZONE",
+			true,
+			9,
 			0,
 			false,
 			0,
-			reflect.TypeOf(new(interface{})).Elem(),
+			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"IPADDRESS",
-			false,
-			0,
+			"TIMESTAMP",
+			true,
+			9,
 			0,
 			false,
 			0,
-			reflect.TypeOf(sql.NullString{}),
+			reflect.TypeOf(sql.NullTime{}),
 		},
 		{
-			"UUID",
-			false,
-			0,
+			"TIMESTAMP WITH TIME ZONE",
+			true,
+			9,
 			0,
 			false,
 			0,
-			reflect.TypeOf(sql.NullString{}),
+			reflect.TypeOf(sql.NullTime{}),
 		},
 	}
-	actualTypes := make([]columnType, 33)
-	for i, column := range columnTypes {
-		actualTypes[i].typeName = column.DatabaseTypeName()
-		actualTypes[i].precision, actualTypes[i].scale, actualTypes[i].hasScale = column.DecimalSize()
-		actualTypes[i].length, actualTypes[i].hasLength = column.Length()
-		actualTypes[i].scanType = column.ScanType()
+	actualTypes := make([]columnType, 4)
+	for i, column := range columnTypes {
+		actualTypes[i].typeName = column.DatabaseTypeName()
+		actualTypes[i].precision, actualTypes[i].scale, actualTypes[i].hasScale = column.DecimalSize()
+		actualTypes[i].length, actualTypes[i].hasLength = column.Length()
+		actualTypes[i].scanType = column.ScanType()
+	}
+
+	assert.Equal(t, actualTypes, expectedTypes)
+
+	assert.True(t, rows.Next())
+	require.NoError(t, rows.Err())
+
+}
+
+func TestMaxResponseBodySizeConfig(t *testing.T) {
+	c := &Config{
+		ServerURI:           "http://foobar@localhost:8080",
+		MaxResponseBodySize: 1024,
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?maxResponseBodySize=1024&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	c.MaxResponseBodySize = -1
+	_, err = c.FormatDSN()
+	assert.Error(t, err)
+
+	_, err = newConn("http://foobar@localhost:8080?maxResponseBodySize=big")
+	assert.Error(t, err)
+}
+
+func TestReadBufferSizeConfig(t *testing.T) {
+	c := &Config{
+		ServerURI:      "http://foobar@localhost:8080",
+		ReadBufferSize: 65536,
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?readBufferSize=65536&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	conn, err := newConn(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, 65536, conn.readBufferSize)
+
+	c.ReadBufferSize = -1
+	_, err = c.FormatDSN()
+	assert.Error(t, err)
+
+	for _, v := range []string{"big", "-1"} {
+		_, err = newConn("http://foobar@localhost:8080?readBufferSize=" + v)
+		assert.Error(t, err, "invalid read buffer size %q accepted", v)
+	}
+}
+
+func TestMaxResponseBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&stmtResponse{
+			ID:    "fake-query",
+			Error: ErrTrino{Message: strings.Repeat("a", 1024)},
+		})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?maxResponseBodySize=512")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Query("SELECT 1")
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestQueryCancellation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&stmtResponse{
+			Error: ErrTrino{
+				ErrorName: "USER_CANCELLED",
+			},
+		})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Query("SELECT 1")
+	assert.EqualError(t, err, ErrQueryCancelled.Error(), "unexpected error")
+}
+
+func TestPrepareContext(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	t.Cleanup(ts.Close)
+	t.Cleanup(func() { close(done) })
+
+	conn, err := newConn(ts.URL + "?explicitPrepare=true")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = conn.PrepareContext(ctx, "SELECT ?")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the statement is prepared by the server with its first execution, which respects the context
+	stmt, err := conn.PrepareContext(context.Background(), "SELECT ?")
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = stmt.(driver.StmtQueryContext).QueryContext(ctx, []driver.NamedValue{{Ordinal: 1, Value: int64(1)}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestConnExecContext(t *testing.T) {
+	var queries []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			queries = append(queries, string(body))
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:          "fake-query",
+			UpdateType:  "INSERT",
+			UpdateCount: 2,
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	conn, err := newConn(ts.URL)
+	require.NoError(t, err)
+	result, err := conn.ExecContext(context.Background(), "CREATE TABLE t (a bigint)", nil)
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	_, err = db.Exec("INSERT INTO t VALUES (?), (?)", []int64{1}, big.NewInt(2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE t (a bigint)",
+		"EXECUTE IMMEDIATE 'INSERT INTO t VALUES (?), (?)' USING ARRAY[1], DECIMAL '2'",
+	}, queries)
+}
+
+func TestServerStartingUpRetry(t *testing.T) {
+	var posts int
+	failures := 2
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/" + strconv.Itoa(posts),
+			})
+			return
+		}
+		if posts <= failures {
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:    "fake-query",
+				Error: ErrTrino{ErrorName: "SERVER_STARTING_UP", ErrorType: "INTERNAL_ERROR", Message: "Trino server is still initializing"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "fake-query",
+			Columns: []queryColumn{{Name: "_col0", Type: "integer", TypeSignature: typeSignature{RawType: "integer"}}},
+			Data:    []queryData{{json.Number("1")}},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	rows, err := db.Query("SELECT 1")
+	require.NoError(t, err)
+	var values []int
+	for rows.Next() {
+		var value int
+		require.NoError(t, rows.Scan(&value))
+		values = append(values, value)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{1}, values)
+	assert.Equal(t, 3, posts)
+
+	posts = 0
+	_, err = db.Exec("CREATE TABLE t (a integer)")
+	require.NoError(t, err)
+	assert.Equal(t, 3, posts)
+
+	c := &Config{ServerURI: ts.URL, ServerStartupRetries: 1}
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"?serverStartupRetries=1&source=trino-go-client", dsn)
+	limited, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, limited.Close()) })
+
+	posts = 0
+	_, err = limited.Query("SELECT 1")
+	assert.ErrorIs(t, err, TrinoErrorByName("SERVER_STARTING_UP"))
+	assert.Equal(t, 2, posts)
+}
+
+func TestQueryFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Query("SELECT 1")
+	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
+}
+
+// This test ensures that the fetch method is not generating stack overflow errors.
+// === RUN   TestFetchNoStackOverflow
+// runtime: goroutine stack exceeds 1000000000-byte limit
+// runtime: sp=0x14037b00390 stack=[0x14037b00000, 0x14057b00000]
+// fatal error: stack overflow
+func TestFetchNoStackOverflow(t *testing.T) {
+	previousSetting := debug.SetMaxStack(50 * 1024)
+	defer debug.SetMaxStack(previousSetting)
+	count := 0
+	var buf *bytes.Buffer
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count <= 50 {
+			if buf == nil {
+				buf = new(bytes.Buffer)
+				json.NewEncoder(buf).Encode(&stmtResponse{
+					ID:      "fake-query",
+					NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+				})
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(buf.Bytes())
+			count++
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&stmtResponse{
+			Error: ErrTrino{
+				ErrorName: "TEST",
+			},
+		})
+	}))
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Query("SELECT 1")
+	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
+
+}
+
+func TestRequestID(t *testing.T) {
+	var requestIDs []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get(trinoClientRequestIDHeader))
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	_, err = db.ExecContext(WithRequestID(ctx, "request-1"), "SELECT 1")
+	require.NoError(t, err)
+
+	// the ID is only sent when submitting the query
+	assert.Equal(t, []string{"", "", "request-1", ""}, requestIDs)
+}
+
+func TestWrapContext(t *testing.T) {
+	var users [][]string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		users = append(users, r.Header.Values(trinoUserHeader))
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "20210817_140827_00000_arvdv",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+				Stats:   stmtStats{State: "QUEUED"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "20210817_140827_00000_arvdv",
+			Columns: []queryColumn{{Name: "_col0", Type: "integer", TypeSignature: typeSignature{RawType: "integer"}}},
+			Data:    []queryData{{json.Number("2")}},
+			Stats:   stmtStats{State: "FINISHED"},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?user=default")
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	query := func(ctx context.Context, args ...interface{}) {
+		t.Helper()
+		rows, err := db.QueryContext(ctx, "SELECT 2", args...)
+		require.NoError(t, err)
+		for rows.Next() {
+		}
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+	}
+
+	ctx := WrapContext(context.Background(), WithUser("alice"))
+	query(ctx)
+	assert.Equal(t, [][]string{{"alice"}, {"alice"}}, users)
+
+	users = nil
+	query(ctx, sql.Named(trinoUserHeader, "bob"))
+	assert.Equal(t, [][]string{{"bob"}, {"bob"}}, users, "NamedArgs should take precedence")
+
+	callback := &TestQueryProgressCallback{
+		progressMap: make(map[time.Time]float64),
+		statusMap:   make(map[time.Time]string),
+	}
+	users = nil
+	query(WrapContext(ctx, WithProgressCallback(callback, time.Millisecond)))
+	assert.Equal(t, [][]string{{"alice"}, {"alice"}}, users, "options should be combined with the ones already in the context")
+	assert.NotEmpty(t, callback.queryIDs)
+
+	calls := len(callback.queryIDs)
+	query(ctx)
+	assert.Len(t, callback.queryIDs, calls, "the progress callback should not be kept by the connection")
+
+	_, err = db.QueryContext(WrapContext(ctx, WithProgressCallback(callback, 0)), "SELECT 2")
+	assert.ErrorIs(t, err, ErrInvalidProgressCallbackHeader)
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	c := &Config{ServerURI: ts.URL, UserAgent: "my-service/1.0"}
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"?source=trino-go-client&userAgent=my-service%2F1.0", dsn)
+
+	for _, dsn := range []string{ts.URL, dsn} {
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+		_, err = db.Exec("SELECT 1")
+		require.NoError(t, err)
+		assert.NoError(t, db.Close())
+	}
+	require.Len(t, userAgents, 2)
+	assert.True(t, strings.HasPrefix(userAgents[0], "trino-go-client/"), userAgents[0])
+	assert.Equal(t, "my-service/1.0", userAgents[1])
+}
+
+func TestQueryTags(t *testing.T) {
+	var clientTags []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientTags = append(clientTags, r.Header.Get(trinoClientTagsHeader))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	ctx := context.Background()
+	for _, ctx := range []context.Context{
+		ctx,
+		WithQueryTag(ctx, "etl"),
+		WithQueryTags(WithQueryTag(ctx, "etl"), "daily", "orders"),
+	} {
+		_, err = db.ExecContext(ctx, "SELECT 1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"", "etl", "etl,daily,orders"}, clientTags)
+
+	_, err = db.ExecContext(WithQueryTag(ctx, "a,b"), "SELECT 1")
+	assert.Error(t, err, "tag with a comma sent with no error")
+}
+
+func TestWithSessionProperties(t *testing.T) {
+	var sessions [][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessions = append(sessions, r.Header.Values(trinoSessionHeader))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL+"?session_properties=query_priority%3A1%3Bjoin_distribution_type%3ABROADCAST")
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	ctx := context.Background()
+	for _, ctx := range []context.Context{
+		WithSessionProperties(ctx, map[string]string{"query_priority": "5"}),
+		WithSessionProperties(
+			WithSessionProperties(ctx, map[string]string{"query_max_run_time": "1h"}),
+			map[string]string{"catalog.prop": "a/b"},
+		),
+		ctx,
+	} {
+		_, err = db.ExecContext(ctx, "SELECT 1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, [][]string{
+		{"join_distribution_type=BROADCAST", "query_priority=5"},
+		{"query_priority=1", "join_distribution_type=BROADCAST", "catalog.prop=a%2Fb", "query_max_run_time=1h"},
+		{"query_priority=1", "join_distribution_type=BROADCAST"},
+	}, sessions)
+
+	_, err = db.ExecContext(WithSessionProperties(ctx, map[string]string{"query_priority": ""}), "SELECT 1")
+	assert.Error(t, err, "empty session property value sent with no error")
+}
+
+func TestSessionCache(t *testing.T) {
+	var queries []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			queries = append(queries, string(body))
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		switch query := queries[len(queries)-1]; {
+		case strings.HasPrefix(query, "SET SESSION join_distribution_type"):
+			_, value, _ := strings.Cut(query, "'")
+			w.Header().Set(trinoSetSessionHeader, "join_distribution_type="+url.QueryEscape(strings.TrimSuffix(value, "'")))
+		case strings.HasPrefix(query, "SET SESSION query_max_run_time"):
+			w.Header().Set(trinoSetSessionHeader, "query_max_run_time=1h")
+		case strings.HasPrefix(query, "RESET SESSION"):
+			w.Header().Set(trinoClearSessionHeader, "join_distribution_type")
+		}
+		json.NewEncoder(w).Encode(&queryResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, conn.Close()) })
+
+	for _, query := range []string{
+		"SET SESSION join_distribution_type='BROADCAST'",
+		"SET SESSION join_distribution_type = 'BROADCAST';",
+		"set session JOIN_DISTRIBUTION_TYPE='BROADCAST'",
+		"SET SESSION join_distribution_type='PARTITIONED'",
+		"SET SESSION join_distribution_type='PARTITIONED' || ''",
+		"SET SESSION query_max_run_time='1h'",
+		"SET SESSION query_max_run_time='1h'",
+		"RESET SESSION join_distribution_type",
+		"SET SESSION join_distribution_type='PARTITIONED'",
+	} {
+		_, err = conn.ExecContext(ctx, query)
+		require.NoError(t, err, query)
+	}
+	assert.Equal(t, []string{
+		"SET SESSION join_distribution_type='BROADCAST'",
+		"SET SESSION join_distribution_type='PARTITIONED'",
+		"SET SESSION join_distribution_type='PARTITIONED' || ''",
+		"SET SESSION query_max_run_time='1h'",
+		"RESET SESSION join_distribution_type",
+		"SET SESSION join_distribution_type='PARTITIONED'",
+	}, queries)
+
+	// the session properties are forgotten when the connection is reused from the pool
+	require.NoError(t, conn.Close())
+	conn, err = db.Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "SET SESSION query_max_run_time='1h'")
+	require.NoError(t, err)
+	assert.Len(t, queries, 7)
+}
+
+func TestLockingNotSupported(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID: "fake-query",
+			Error: ErrTrino{
+				ErrorName: "SYNTAX_ERROR",
+				ErrorType: "USER_ERROR",
+				Message:   "line 1:29: mismatched input 'FOR'. Expecting: <EOF>",
+			},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	for _, query := range []string{
+		"SELECT * FROM accounts WHERE id = 1 FOR UPDATE",
+		"SELECT * FROM accounts WHERE id = 1 for no key update",
+		"SELECT * FROM accounts WHERE id = 1 FOR SHARE NOWAIT",
+	} {
+		_, err = db.Exec(query)
+		assert.ErrorIs(t, err, ErrLockingNotSupported, query)
+		assert.ErrorIs(t, err, TrinoErrorByName("SYNTAX_ERROR"), query)
+		var qferr *ErrQueryFailed
+		assert.ErrorAs(t, err, &qferr, query)
+	}
+
+	// other syntax errors aren't reported as locking errors
+	_, err = db.Exec("SELECT * FROM accounts WHERE name = 'FOR UPDATE' FOR")
+	assert.NotErrorIs(t, err, ErrLockingNotSupported)
+	_, err = db.Exec("SELECT * FROM accounts FORMAT")
+	assert.NotErrorIs(t, err, ErrLockingNotSupported)
+	assert.Error(t, err)
+}
+
+func TestTraceQueryText(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "server_error") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:    "fake-query",
+			Error: ErrTrino{ErrorName: "TEST"},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	longQuery := "SELECT '" + strings.Repeat("a", 5000) + "'"
+	for _, tt := range []struct {
+		name      string
+		dsn       string
+		query     string
+		wantQuery string
+	}{
+		{"disabled", ts.URL, "SELECT 1", ""},
+		{"query error", ts.URL + "?traceQueryText=true", "SELECT 1", "SELECT 1"},
+		{"server error", ts.URL + "?traceQueryText=true", "SELECT 'server_error'", "SELECT 'server_error'"},
+		{"truncated", ts.URL + "?traceQueryText=true", longQuery, longQuery[:maxTracedQueryLength]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("trino", tt.dsn)
+			require.NoError(t, err)
+			t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+			_, err = db.Exec(tt.query)
+			var qferr *ErrQueryFailed
+			require.ErrorAs(t, err, &qferr)
+			assert.Equal(t, tt.wantQuery, qferr.Query)
+			if tt.wantQuery == "" {
+				assert.NotContains(t, err.Error(), "query=")
+			} else {
+				assert.True(t, strings.HasSuffix(err.Error(), fmt.Sprintf(" query=%q", tt.wantQuery)), err.Error())
+			}
+		})
+	}
+}
+
+func TestSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode.")
+	}
+	c := &Config{
+		ServerURI:           *integrationServerFlag,
+		SessionProperties:   map[string]string{"query_priority": "1"},
+		CompressionDisabled: true,
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	db, err := sql.Open("trino", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	_, err = db.Exec("SET SESSION join_distribution_type='BROADCAST'")
+	require.NoError(t, err, "Failed executing query")
+
+	row := db.QueryRow("SHOW SESSION LIKE 'join_distribution_type'")
+	var name string
+	var value string
+	var defaultValue string
+	var typeName string
+	var description string
+	err = row.Scan(&name, &value, &defaultValue, &typeName, &description)
+	require.NoError(t, err, "Failed executing query")
+
+	assert.Equal(t, "BROADCAST", value)
+
+	_, err = db.Exec("RESET SESSION join_distribution_type")
+	require.NoError(t, err, "Failed executing query")
+
+	row = db.QueryRow("SHOW SESSION LIKE 'join_distribution_type'")
+	err = row.Scan(&name, &value, &defaultValue, &typeName, &description)
+	require.NoError(t, err, "Failed executing query")
+
+	assert.Equal(t, "AUTOMATIC", value)
+}
+
+func TestQueryIDContext(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			b, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(b), "FAIL") {
+				json.NewEncoder(w).Encode(&stmtResponse{
+					ID:    "failed-query",
+					Error: ErrTrino{ErrorName: "TEST"},
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID: "fake-query",
+			Columns: []queryColumn{
+				{
+					Name:          "_col0",
+					Type:          "integer",
+					TypeSignature: typeSignature{RawType: "integer"},
+				},
+			},
+			Data: []queryData{{json.Number("1")}},
+		})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	queryID, rows, err := QueryIDContext(context.Background(), db, "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "fake-query", queryID)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+
+	queryID, _, err = QueryIDContext(context.Background(), db, "SELECT FAIL")
+	assert.Error(t, err)
+	assert.Equal(t, "failed-query", queryID)
+}
+
+func TestCancelQuery(t *testing.T) {
+	var cancelled []string
+	var users []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/v1/query/unknown" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		cancelled = append(cancelled, r.URL.Path)
+		users = append(users, r.Header.Get(trinoUserHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	serverURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	serverURL.User = url.User("alice")
+	db, err := sql.Open("trino", serverURL.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	require.NoError(t, CancelQuery(context.Background(), db, "20210817_140827_00000_arvdv"))
+	assert.Equal(t, []string{"/v1/query/20210817_140827_00000_arvdv"}, cancelled)
+	assert.Equal(t, []string{"alice"}, users)
+
+	var qferr *ErrQueryFailed
+	require.ErrorAs(t, CancelQuery(context.Background(), db, "unknown"), &qferr)
+	assert.Equal(t, http.StatusNotFound, qferr.HTTPStatus())
+
+	assert.Error(t, CancelQuery(context.Background(), db, ""))
+
+	other := sql.OpenDB(otherConnector{})
+	t.Cleanup(func() { assert.NoError(t, other.Close()) })
+	assert.ErrorContains(t, CancelQuery(context.Background(), other, "20210817_140827_00000_arvdv"), "not a Trino connection")
+}
+
+// otherConnector connects to a database with a driver other than Trino.
+type otherConnector struct{}
+
+func (otherConnector) Connect(context.Context) (driver.Conn, error) { return otherConn{}, nil }
+func (otherConnector) Driver() driver.Driver                        { return nil }
+
+type otherConn struct{}
+
+func (otherConn) Prepare(string) (driver.Stmt, error) { return nil, ErrOperationNotSupported }
+func (otherConn) Close() error                        { return nil }
+func (otherConn) Begin() (driver.Tx, error)           { return nil, ErrOperationNotSupported }
+
+func TestHealthCheck(t *testing.T) {
+	starting := true
+	var queries int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/info" {
+			fmt.Fprintf(w, `{"nodeVersion":{"version":"455"},"environment":"test","coordinator":true,"starting":%t,"uptime":"1.50h"}`, starting)
+			return
+		}
+		queries++
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	status, err := HealthCheck(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatus{ServerVersion: "455", Uptime: 90 * time.Minute}, status)
+	assert.Equal(t, 0, queries)
+
+	starting = false
+	status, err = HealthCheck(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatus{Healthy: true, ServerVersion: "455", StartupComplete: true, Uptime: 90 * time.Minute}, status)
+	assert.Equal(t, 1, queries)
+
+	ts.Close()
+	status, err = HealthCheck(context.Background(), db)
+	assert.Error(t, err)
+	assert.False(t, status.Healthy)
+
+	other := sql.OpenDB(otherConnector{})
+	t.Cleanup(func() { assert.NoError(t, other.Close()) })
+	_, err = HealthCheck(context.Background(), other)
+	assert.ErrorContains(t, err, "not a Trino connection")
+}
+
+func TestParseServerDuration(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"":         0,
+		"10.00s":   10 * time.Second,
+		"1.50h":    90 * time.Minute,
+		"2.00d":    48 * time.Hour,
+		"250.00ms": 250 * time.Millisecond,
+	} {
+		d, err := parseServerDuration(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, d, s)
 	}
+	_, err := parseServerDuration("1.5x")
+	assert.Error(t, err)
+}
+
+func TestQueryInfoURIContext(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				InfoURI: ts.URL + "/ui/query.html?fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID:      "fake-query",
+			InfoURI: ts.URL + "/ui/query.html?fake-query",
+			Columns: []queryColumn{{Name: "_col0", Type: "integer", TypeSignature: typeSignature{RawType: "integer"}}},
+			Data:    []queryData{{json.Number("1")}, {json.Number("2")}},
+		})
+	}))
+	t.Cleanup(ts.Close)
 
-	assert.Equal(t, actualTypes, expectedTypes)
+	for _, enabled := range []bool{true, false} {
+		dsn, err := (&Config{ServerURI: ts.URL, EnableQueryInfo: enabled}).FormatDSN()
+		require.NoError(t, err)
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+		uri, rows, err := QueryInfoURIContext(context.Background(), db, "SELECT 1")
+		require.NoError(t, err)
+		if enabled {
+			assert.Equal(t, ts.URL+"/ui/query.html?fake-query", uri)
+		} else {
+			assert.Empty(t, uri)
+		}
+		require.NoError(t, rows.Close())
+	}
 }
 
-func TestMaxGoPrecisionDateTime(t *testing.T) {
-	c := &Config{
-		ServerURI:         *integrationServerFlag,
-		SessionProperties: map[string]string{"query_priority": "1"},
-	}
+func TestBatch(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		queries = append(queries, string(b))
+		if strings.Contains(string(b), "missing") {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:    "failed-query",
+				Error: ErrTrino{ErrorName: "TABLE_NOT_FOUND"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query", UpdateCount: int64(len(queries))})
+	}))
 
-	dsn, err := c.FormatDSN()
-	require.NoError(t, err)
+	t.Cleanup(ts.Close)
 
-	db, err := sql.Open("trino", dsn)
+	db, err := sql.Open("trino", ts.URL)
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	rows, err := db.Query(`SELECT
-  cast(current_time as time(9)) AS timep,
-  cast(current_time as time(9) with time zone) AS timeptz,
-  cast(current_time as timestamp(9)) AS tsp,
-  cast(current_time as timestamp(9) with time zone) AS tsptz`)
-	require.NoError(t, err, "Failed executing query")
-	assert.NotNil(t, rows)
-
-	columns, err := rows.Columns()
-	require.NoError(t, err, "Failed reading result columns")
+	batch := NewBatch(db)
+	require.NoError(t, batch.Add("INSERT INTO t VALUES (?, ?)", 1, "it's"))
+	require.NoError(t, batch.Add("DELETE FROM t"))
+	assert.Error(t, batch.Add("INSERT INTO t VALUES (?)", 1.5), "unsupported argument added with no error")
+	assert.Error(t, batch.Add("INSERT INTO t VALUES (?)", sql.Named("X-Trino-User", "Alice")), "named argument added with no error")
 
-	assert.Equal(t, 4, len(columns), "Expected 4 result column")
-	expectedNames := []string{
-		"timep",
-		"timeptz",
-		"tsp",
-		"tsptz",
+	results, err := batch.Exec(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"EXECUTE IMMEDIATE 'INSERT INTO t VALUES (?, ?)' USING 1, 'it''s'",
+		"DELETE FROM t",
+	}, queries)
+	require.Len(t, results, 2)
+	for i, result := range results {
+		rowsAffected, err := result.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), rowsAffected)
 	}
-	assert.Equal(t, expectedNames, columns)
 
-	columnTypes, err := rows.ColumnTypes()
-	require.NoError(t, err, "Failed reading result column types")
+	queries = nil
+	batch = NewBatch(db)
+	require.NoError(t, batch.Add("INSERT INTO t VALUES (?)", 1))
+	require.NoError(t, batch.Add("INSERT INTO missing VALUES (?)", 2))
+	require.NoError(t, batch.Add("INSERT INTO t VALUES (?)", 3))
+	results, err = batch.Exec(context.Background())
+	assert.ErrorContains(t, err, "batch statement 1 failed")
+	assert.Len(t, results, 1)
+	assert.Len(t, queries, 2)
+}
 
-	assert.Equal(t, 4, len(columnTypes), "Expected 4 result column type")
+func TestWarningCollector(t *testing.T) {
+	deprecated := TrinoWarning{WarningCode: WarningCode{Code: 1, Name: "DEPRECATED_SYNTAX"}, Message: "deprecated", Level: "WARNING"}
+	plan := TrinoWarning{WarningCode: WarningCode{Code: 2, Name: "PLAN"}, Message: "slow plan"}
+	last := TrinoWarning{WarningCode: WarningCode{Code: 3, Name: "LAST"}, Message: "last"}
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// like Trino, every response contains all the warnings of the query so far
+		switch r.URL.Path {
+		case "/v1/statement":
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:       "fake-query",
+				NextURI:  ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+				Warnings: []TrinoWarning{deprecated, plan},
+			})
+		case "/v1/statement/20210817_140827_00000_arvdv/1":
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID:       "fake-query",
+				NextURI:  ts.URL + "/v1/statement/20210817_140827_00000_arvdv/2",
+				Warnings: []TrinoWarning{deprecated, plan},
+			})
+		default:
+			json.NewEncoder(w).Encode(&queryResponse{
+				ID: "fake-query",
+				Columns: []queryColumn{
+					{
+						Name:          "_col0",
+						Type:          "integer",
+						TypeSignature: typeSignature{RawType: "integer"},
+					},
+				},
+				Data:     []queryData{{json.Number("1")}},
+				Warnings: []TrinoWarning{deprecated, plan, last},
+			})
+		}
+	}))
 
-	type columnType struct {
-		typeName  string
-		hasScale  bool
-		precision int64
-		scale     int64
-		hasLength bool
-		length    int64
-		scanType  reflect.Type
-	}
-	expectedTypes := []columnType{
-		{
-			"TIME",
-			true,
-			9,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullTime{}),
-		},
-		{
-			"TIME WITH TIME ZONE",
-			true,
-			9,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullTime{}),
-		},
-		{
-			"TIMESTAMP",
-			true,
-			9,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullTime{}),
-		},
-		{
-			"TIMESTAMP WITH TIME ZONE",
-			true,
-			9,
-			0,
-			false,
-			0,
-			reflect.TypeOf(sql.NullTime{}),
-		},
-	}
-	actualTypes := make([]columnType, 4)
-	for i, column := range columnTypes {
-		actualTypes[i].typeName = column.DatabaseTypeName()
-		actualTypes[i].precision, actualTypes[i].scale, actualTypes[i].hasScale = column.DecimalSize()
-		actualTypes[i].length, actualTypes[i].hasLength = column.Length()
-		actualTypes[i].scanType = column.ScanType()
-	}
+	t.Cleanup(ts.Close)
 
-	assert.Equal(t, actualTypes, expectedTypes)
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
 
-	assert.True(t, rows.Next())
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	ctx, collector := WithWarningCollector(context.Background())
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
 	require.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, []TrinoWarning{deprecated, plan, last}, collector.Warnings())
 
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Len(t, collector.Warnings(), 3, "warnings collected for a query without the collector")
 }
 
-func TestQueryCancellation(t *testing.T) {
+func TestTransactionID(t *testing.T) {
+	var transactionIDs []string
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transactionIDs = append(transactionIDs, r.Header.Get(trinoTransactionHeader))
+		b, _ := io.ReadAll(r.Body)
+		switch string(b) {
+		case "START TRANSACTION":
+			w.Header().Set(trinoStartedTransactionHeader, "started-id")
+		case "COMMIT":
+			w.Header().Set(trinoClearTransactionHeader, "true")
+		}
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(&stmtResponse{
-			Error: ErrTrino{
-				ErrorName: "USER_CANCELLED",
-			},
-		})
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
 	}))
 
 	t.Cleanup(ts.Close)
@@ -1050,61 +3898,74 @@ func TestQueryCancellation(t *testing.T) {
 		assert.NoError(t, db.Close())
 	})
 
-	_, err = db.Query("SELECT 1")
-	assert.EqualError(t, err, ErrQueryCancelled.Error(), "unexpected error")
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+
+	transactionID := func() string {
+		var txID string
+		require.NoError(t, conn.Raw(func(driverConn any) error {
+			txID = driverConn.(*Conn).TransactionID()
+			return nil
+		}))
+		return txID
+	}
+
+	for _, query := range []string{"START TRANSACTION", "SELECT 1"} {
+		_, err = conn.ExecContext(ctx, query)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "started-id", transactionID())
+	for _, query := range []string{"COMMIT", "SELECT 2"} {
+		_, err = conn.ExecContext(ctx, query)
+		require.NoError(t, err)
+	}
+	assert.Empty(t, transactionID())
+	_, err = conn.ExecContext(WithTransactionID(ctx, "other-id"), "SELECT 3")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	assert.Equal(t, []string{"", "started-id", "started-id", "", "other-id"}, transactionIDs)
 }
 
-func TestQueryFailure(t *testing.T) {
+func TestResetSessionClearsTransaction(t *testing.T) {
+	var transactionIDs []string
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusInternalServerError)
+		transactionIDs = append(transactionIDs, r.Header.Get(trinoTransactionHeader))
+		b, _ := io.ReadAll(r.Body)
+		if string(b) == "START TRANSACTION" {
+			w.Header().Set(trinoStartedTransactionHeader, "started-id")
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
 	}))
 
 	t.Cleanup(ts.Close)
 
 	db, err := sql.Open("trino", ts.URL)
 	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	_, err = db.Query("SELECT 1")
-	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
+	for _, query := range []string{"START TRANSACTION", "SELECT 1"} {
+		_, err = db.Exec(query)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"", ""}, transactionIDs, "transaction used after the connection was returned to the pool")
 }
 
-// This test ensures that the fetch method is not generating stack overflow errors.
-// === RUN   TestFetchNoStackOverflow
-// runtime: goroutine stack exceeds 1000000000-byte limit
-// runtime: sp=0x14037b00390 stack=[0x14037b00000, 0x14057b00000]
-// fatal error: stack overflow
-func TestFetchNoStackOverflow(t *testing.T) {
-	previousSetting := debug.SetMaxStack(50 * 1024)
-	defer debug.SetMaxStack(previousSetting)
-	count := 0
-	var buf *bytes.Buffer
-	var ts *httptest.Server
-	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if count <= 50 {
-			if buf == nil {
-				buf = new(bytes.Buffer)
-				json.NewEncoder(buf).Encode(&stmtResponse{
-					ID:      "fake-query",
-					NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
-				})
-			}
-			w.WriteHeader(http.StatusOK)
-			w.Write(buf.Bytes())
-			count++
-			return
-		}
+func TestUnsupportedHeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(trinoSetRoleHeader, "foo")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(&stmtResponse{
-			Error: ErrTrino{
-				ErrorName: "TEST",
-			},
-		})
 	}))
 
+	t.Cleanup(ts.Close)
+
 	db, err := sql.Open("trino", ts.URL)
 	require.NoError(t, err)
 
@@ -1113,61 +3974,78 @@ func TestFetchNoStackOverflow(t *testing.T) {
 	})
 
 	_, err = db.Query("SELECT 1")
-	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
-
+	assert.EqualError(t, err, ErrUnsupportedHeader.Error(), "unexpected error")
 }
 
-func TestSession(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping test in short mode.")
-	}
-	err := RegisterCustomClient("uncompressed", &http.Client{Transport: &http.Transport{DisableCompression: true}})
-	if err != nil {
-		t.Fatal(err)
-	}
-	c := &Config{
-		ServerURI:         *integrationServerFlag + "?custom_client=uncompressed",
-		SessionProperties: map[string]string{"query_priority": "1"},
-	}
-
-	dsn, err := c.FormatDSN()
+func TestSSLCertPath(t *testing.T) {
+	db, err := sql.Open("trino", "https://localhost:9?SSLCertPath=/tmp/invalid_test.cert")
 	require.NoError(t, err)
 
-	db, err := sql.Open("trino", dsn)
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
+
+	want := "Error loading SSL Cert File"
+	err = db.Ping()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), want)
+}
+
+func TestWithoutSSLCertPath(t *testing.T) {
+	db, err := sql.Open("trino", "https://localhost:9")
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	_, err = db.Exec("SET SESSION join_distribution_type='BROADCAST'")
-	require.NoError(t, err, "Failed executing query")
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, conn.Close())
+}
 
-	row := db.QueryRow("SHOW SESSION LIKE 'join_distribution_type'")
-	var name string
-	var value string
-	var defaultValue string
-	var typeName string
-	var description string
-	err = row.Scan(&name, &value, &defaultValue, &typeName, &description)
-	require.NoError(t, err, "Failed executing query")
+func TestPing(t *testing.T) {
+	var queries []string
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			b, _ := io.ReadAll(r.Body)
+			queries = append(queries, string(b))
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID: "fake-query",
+			Columns: []queryColumn{
+				{
+					Name:          "_col0",
+					Type:          "integer",
+					TypeSignature: typeSignature{RawType: "integer"},
+				},
+			},
+			Data: []queryData{{json.Number("1")}},
+		})
+	}))
 
-	assert.Equal(t, "BROADCAST", value)
+	t.Cleanup(ts.Close)
 
-	_, err = db.Exec("RESET SESSION join_distribution_type")
-	require.NoError(t, err, "Failed executing query")
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
 
-	row = db.QueryRow("SHOW SESSION LIKE 'join_distribution_type'")
-	err = row.Scan(&name, &value, &defaultValue, &typeName, &description)
-	require.NoError(t, err, "Failed executing query")
+	t.Cleanup(func() {
+		assert.NoError(t, db.Close())
+	})
 
-	assert.Equal(t, "AUTOMATIC", value)
+	require.NoError(t, db.Ping())
+	assert.Equal(t, []string{"SELECT 1"}, queries)
 }
 
-func TestUnsupportedHeader(t *testing.T) {
+func TestPingFailure(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set(trinoSetRoleHeader, "foo")
-		w.WriteHeader(http.StatusOK)
+		w.WriteHeader(http.StatusInternalServerError)
 	}))
 
 	t.Cleanup(ts.Close)
@@ -1179,33 +4057,74 @@ func TestUnsupportedHeader(t *testing.T) {
 		assert.NoError(t, db.Close())
 	})
 
-	_, err = db.Query("SELECT 1")
-	assert.EqualError(t, err, ErrUnsupportedHeader.Error(), "unexpected error")
+	err = db.Ping()
+	assert.IsTypef(t, new(ErrQueryFailed), err, "unexpected error: %w", err)
 }
 
-func TestSSLCertPath(t *testing.T) {
-	db, err := sql.Open("trino", "https://localhost:9?SSLCertPath=/tmp/invalid_test.cert")
+func TestPingCancellation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	want := "Error loading SSL Cert File"
-	err = db.Ping()
-	require.Error(t, err)
-	require.Contains(t, err.Error(), want)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	t.Cleanup(cancel)
+
+	assert.ErrorIs(t, db.PingContext(ctx), context.DeadlineExceeded)
 }
 
-func TestWithoutSSLCertPath(t *testing.T) {
-	db, err := sql.Open("trino", "https://localhost:9")
+func TestNextResultSet(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(&queryResponse{
+			ID: "fake-query",
+			Columns: []queryColumn{
+				{
+					Name:          "_col0",
+					Type:          "integer",
+					TypeSignature: typeSignature{RawType: "integer"},
+				},
+			},
+			Data: []queryData{{json.Number("1")}, {json.Number("2")}},
+		})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
 		assert.NoError(t, db.Close())
 	})
 
-	assert.NoError(t, db.Ping())
+	rows, err := db.Query("SELECT 1")
+	require.NoError(t, err)
+
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 2, count)
+	assert.False(t, rows.NextResultSet())
+	assert.NoError(t, rows.Err())
+	assert.NoError(t, rows.Close())
 }
 
 func TestUnsupportedTransaction(t *testing.T) {
@@ -1380,6 +4299,19 @@ func TestTypeConversion(t *testing.T) {
 			ResponseUnmarshalledSample: "2017-07-10 01:02:03.000-04:00",
 			ExpectedGoValue:            time.Date(2017, 7, 10, 1, 2, 3, 0, time.FixedZone("", -4*3600)),
 		},
+		{
+			DataType:                   "timestamp(3) with local time zone",
+			RawType:                    "timestamp with local time zone",
+			Arguments:                  []typeArgument{{Kind: KIND_LONG, long: 3}},
+			ResponseUnmarshalledSample: "2017-07-10 01:02:03.000",
+			ExpectedGoValue:            time.Date(2017, 7, 10, 1, 2, 3, 0, time.Local),
+		},
+		{
+			DataType:                   "timestamp with local time zone",
+			RawType:                    "timestamp with local time zone",
+			ResponseUnmarshalledSample: "2017-07-10 01:02:03.000 UTC",
+			ExpectedGoValue:            time.Date(2017, 7, 10, 1, 2, 3, 0, utc),
+		},
 		{
 			DataType:                   "timestamp",
 			RawType:                    "timestamp",
@@ -1547,6 +4479,24 @@ func TestTypeConversion(t *testing.T) {
 			ResponseUnmarshalledSample: "Point (0 0)",
 			ExpectedGoValue:            "Point (0 0)",
 		},
+		{
+			DataType:                   "HyperLogLog",
+			RawType:                    "HyperLogLog",
+			ResponseUnmarshalledSample: "AgwBAIADRAA=",
+			ExpectedGoValue:            []byte{0x02, 0x0c, 0x01, 0x00, 0x80, 0x03, 0x44, 0x00},
+		},
+		{
+			DataType:                   "P4HyperLogLog",
+			RawType:                    "P4HyperLogLog",
+			ResponseUnmarshalledSample: "AwwAAA==",
+			ExpectedGoValue:            []byte{0x03, 0x0c, 0x00, 0x00},
+		},
+		{
+			DataType:                   "qdigest(bigint)",
+			RawType:                    "qdigest",
+			ResponseUnmarshalledSample: "AHsUrkfheoQ/",
+			ExpectedGoValue:            "AHsUrkfheoQ/",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -1574,6 +4524,83 @@ func TestTypeConversion(t *testing.T) {
 	}
 }
 
+func TestHyperLogLogConversion(t *testing.T) {
+	converter, err := newTypeConverter("HyperLogLog", typeSignature{RawType: "HyperLogLog"})
+	require.NoError(t, err)
+	assert.Equal(t, reflect.TypeOf([]byte{}), converter.scanType)
+
+	_, err = converter.ConvertValue("not base64!")
+	assert.ErrorContains(t, err, "cannot convert not base64! (string) to []byte")
+}
+
+func TestNullSliceStringNulls(t *testing.T) {
+	var s NullSliceString
+	require.NoError(t, s.Scan(nil))
+	assert.False(t, s.Valid, "NULL array scanned as valid")
+	assert.Empty(t, s.SliceString)
+
+	require.NoError(t, s.Scan([]interface{}{nil}))
+	assert.True(t, s.Valid, "array with a NULL element scanned as NULL")
+	assert.Equal(t, []sql.NullString{{}}, s.SliceString)
+
+	require.NoError(t, s.Scan([]interface{}{}))
+	assert.True(t, s.Valid, "empty array scanned as NULL")
+	assert.Empty(t, s.SliceString)
+
+	var s2 NullSlice2String
+	require.NoError(t, s2.Scan([]interface{}{nil, []interface{}{}, []interface{}{nil, "a"}}))
+	assert.True(t, s2.Valid)
+	assert.Equal(t, [][]sql.NullString{nil, {}, {{}, {String: "a", Valid: true}}}, s2.Slice2String)
+	assert.Nil(t, s2.Slice2String[0], "NULL nested array scanned as an empty array")
+	assert.NotNil(t, s2.Slice2String[1], "empty nested array scanned as NULL")
+
+	var s3 NullSlice3String
+	require.NoError(t, s3.Scan([]interface{}{nil, []interface{}{nil}}))
+	assert.Equal(t, [][][]sql.NullString{nil, {nil}}, s3.Slice3String)
+
+	var i2 NullSlice2Int64
+	require.NoError(t, i2.Scan([]interface{}{nil, []interface{}{}}))
+	assert.Nil(t, i2.Slice2Int64[0])
+	assert.NotNil(t, i2.Slice2Int64[1])
+}
+
+func TestNullSliceTimeNulls(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assertNullTimes := func(t *testing.T, expected []bool, actual []NullTime) {
+		t.Helper()
+		require.Len(t, actual, len(expected))
+		for i, valid := range expected {
+			assert.Equal(t, valid, actual[i].Valid, "element %d", i)
+			if valid {
+				assert.True(t, ts.Equal(actual[i].Time), "element %d: got %v", i, actual[i].Time)
+			} else {
+				assert.True(t, actual[i].Time.IsZero(), "element %d: NULL element has a time", i)
+			}
+		}
+	}
+
+	var s NullSliceTime
+	require.NoError(t, s.Scan([]interface{}{"2024-01-01 00:00:00.000", nil}))
+	assert.True(t, s.Valid, "array with a NULL element scanned as NULL")
+	assertNullTimes(t, []bool{true, false}, s.SliceTime)
+
+	var s2 NullSlice2Time
+	require.NoError(t, s2.Scan([]interface{}{nil, []interface{}{nil, "2024-01-01 00:00:00.000"}}))
+	assert.True(t, s2.Valid)
+	require.Len(t, s2.Slice2Time, 2)
+	assert.Nil(t, s2.Slice2Time[0], "NULL nested array scanned as an empty array")
+	assertNullTimes(t, []bool{false, true}, s2.Slice2Time[1])
+
+	var s3 NullSlice3Time
+	require.NoError(t, s3.Scan([]interface{}{nil, []interface{}{nil, []interface{}{"2024-01-01 00:00:00.000", nil}}}))
+	assert.True(t, s3.Valid)
+	require.Len(t, s3.Slice3Time, 2)
+	assert.Nil(t, s3.Slice3Time[0], "NULL nested array scanned as an empty array")
+	require.Len(t, s3.Slice3Time[1], 2)
+	assert.Nil(t, s3.Slice3Time[1][0], "NULL nested array scanned as an empty array")
+	assertNullTimes(t, []bool{true, false}, s3.Slice3Time[1][1])
+}
+
 func TestSliceTypeConversion(t *testing.T) {
 	testcases := []struct {
 		GoType                          string
@@ -1608,6 +4635,15 @@ func TestSliceTypeConversion(t *testing.T) {
 				assert.Equal(t, isValid, v.Valid, "scanner failed")
 			},
 		},
+		{
+			GoType:                          "[]int32",
+			Scanner:                         &NullSliceInt32{},
+			TrinoResponseUnmarshalledSample: []interface{}{json.Number("1")},
+			TestScanner: func(t *testing.T, s sql.Scanner, isValid bool) {
+				v, _ := s.(*NullSliceInt32)
+				assert.Equal(t, isValid, v.Valid, "scanner failed")
+			},
+		},
 
 		{
 			GoType:                          "[]float64",
@@ -1690,6 +4726,15 @@ func TestSlice2TypeConversion(t *testing.T) {
 				assert.Equal(t, isValid, v.Valid, "scanner failed")
 			},
 		},
+		{
+			GoType:                          "[][]int32",
+			Scanner:                         &NullSlice2Int32{},
+			TrinoResponseUnmarshalledSample: []interface{}{[]interface{}{json.Number("1")}},
+			TestScanner: func(t *testing.T, s sql.Scanner, isValid bool) {
+				v, _ := s.(*NullSlice2Int32)
+				assert.Equal(t, isValid, v.Valid, "scanner failed")
+			},
+		},
 		{
 			GoType:                          "[][]float64",
 			Scanner:                         &NullSlice2Float64{},
@@ -1773,6 +4818,15 @@ func TestSlice3TypeConversion(t *testing.T) {
 				assert.Equal(t, isValid, v.Valid, "scanner failed")
 			},
 		},
+		{
+			GoType:                          "[][][]int32",
+			Scanner:                         &NullSlice3Int32{},
+			TrinoResponseUnmarshalledSample: []interface{}{[]interface{}{[]interface{}{json.Number("1")}}},
+			TestScanner: func(t *testing.T, s sql.Scanner, isValid bool) {
+				v, _ := s.(*NullSlice3Int32)
+				assert.Equal(t, isValid, v.Valid, "scanner failed")
+			},
+		},
 		{
 			GoType:                          "[][][]float64",
 			Scanner:                         &NullSlice3Float64{},
@@ -1822,6 +4876,408 @@ func TestSlice3TypeConversion(t *testing.T) {
 	}
 }
 
+func TestNullDurationScan(t *testing.T) {
+	testcases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"0 02:03:04.567", 2*time.Hour + 3*time.Minute + 4*time.Second + 567*time.Millisecond},
+		{"2 00:00:00.000", 48 * time.Hour},
+		{"-1 12:00:00.500", -(36*time.Hour + 500*time.Millisecond)},
+		{"0 00:00:00.000", 0},
+	}
+	for _, tc := range testcases {
+		var d NullDuration
+		require.NoError(t, d.Scan(tc.value), tc.value)
+		assert.True(t, d.Valid)
+		assert.Equal(t, tc.expected, d.Duration, tc.value)
+	}
+
+	var d NullDuration
+	require.NoError(t, d.Scan(nil))
+	assert.False(t, d.Valid)
+
+	for _, value := range []interface{}{"1-2", "02:03:04", "a 02:03:04.000", "0 02:03", "106752 00:00:00.000", json.Number("1")} {
+		assert.Error(t, d.Scan(value), "bogus value %v scanned with no error", value)
+	}
+}
+
+func TestNullIPScan(t *testing.T) {
+	var ip NullIP
+	require.NoError(t, ip.Scan("10.0.0.1"))
+	assert.True(t, ip.Valid)
+	assert.Equal(t, net.ParseIP("10.0.0.1"), ip.IP)
+
+	require.NoError(t, ip.Scan("2001:db8::1"))
+	assert.True(t, ip.Valid)
+	assert.Equal(t, net.ParseIP("2001:db8::1"), ip.IP)
+
+	require.NoError(t, ip.Scan(nil))
+	assert.False(t, ip.Valid)
+	assert.Nil(t, ip.IP)
+
+	for _, value := range []interface{}{"", "10.0.0", "not an ip", json.Number("1")} {
+		assert.Error(t, ip.Scan(value), "bogus value %v scanned with no error", value)
+	}
+}
+
+func TestNullUUIDScan(t *testing.T) {
+	var u NullUUID
+	require.NoError(t, u.Scan("12151fd2-7586-11e9-8f9e-2a86e4085a59"))
+	assert.True(t, u.Valid)
+	assert.Equal(t, [16]byte{0x12, 0x15, 0x1f, 0xd2, 0x75, 0x86, 0x11, 0xe9, 0x8f, 0x9e, 0x2a, 0x86, 0xe4, 0x08, 0x5a, 0x59}, u.UUID)
+
+	require.NoError(t, u.Scan(nil))
+	assert.False(t, u.Valid)
+	assert.Equal(t, [16]byte{}, u.UUID)
+
+	for _, value := range []interface{}{"", "12151fd2758611e98f9e2a86e4085a59", "12151fd2-7586-11e9-8f9e-2a86e4085a5", "12151fd2-7586-11e9-8f9e-2a86e4085a5g", "12151fd2-7586-11e9-8f9e_2a86e4085a59", json.Number("1")} {
+		assert.Error(t, u.Scan(value), "bogus value %v scanned with no error", value)
+	}
+}
+
+func TestNullTypedMapScan(t *testing.T) {
+	var m NullTypedMap[string, int64]
+	require.NoError(t, m.Scan(map[string]interface{}{"a": json.Number("1"), "b": json.Number("2")}))
+	assert.True(t, m.Valid)
+	assert.Equal(t, map[string]int64{"a": 1, "b": 2}, m.Map)
+
+	require.NoError(t, m.Scan(nil))
+	assert.False(t, m.Valid)
+	assert.Empty(t, m.Map)
+
+	assert.Error(t, m.Scan(map[string]interface{}{"a": "b"}), "mismatched value scanned with no error")
+	assert.Error(t, m.Scan([]interface{}{"a"}), "slice scanned into a map with no error")
+
+	var keys NullTypedMap[int32, sql.NullString]
+	require.NoError(t, keys.Scan(map[string]interface{}{"1": "a", "2": nil}))
+	assert.Equal(t, map[int32]sql.NullString{1: {String: "a", Valid: true}, 2: {}}, keys.Map)
+	assert.Error(t, keys.Scan(map[string]interface{}{"a": "b"}), "mismatched key scanned with no error")
+
+	var nested NullTypedMap[string, []interface{}]
+	require.NoError(t, nested.Scan(map[string]interface{}{"a": []interface{}{"b"}}))
+	assert.Equal(t, map[string][]interface{}{"a": {"b"}}, nested.Map)
+}
+
+func TestNullStringMapScan(t *testing.T) {
+	var m NullStringMap
+	require.NoError(t, m.Scan(map[string]interface{}{"a": "x", "b": "y"}))
+	assert.True(t, m.Valid)
+	assert.Equal(t, map[string]string{"a": "x", "b": "y"}, m.Map)
+
+	require.NoError(t, m.Scan(nil))
+	assert.False(t, m.Valid)
+	assert.Empty(t, m.Map)
+
+	assert.Error(t, m.Scan(map[string]interface{}{"a": json.Number("1")}), "number value scanned with no error")
+	assert.Error(t, m.Scan(map[string]interface{}{"a": nil}), "NULL value scanned with no error")
+	assert.Error(t, m.Scan([]interface{}{"a"}), "slice scanned into a map with no error")
+
+	varchar := typeArgument{Kind: KIND_TYPE, typeSignature: typeSignature{RawType: "varchar"}}
+	bigint := typeArgument{Kind: KIND_TYPE, typeSignature: typeSignature{RawType: "bigint"}}
+	for _, tc := range []struct {
+		arguments []typeArgument
+		expected  reflect.Type
+	}{
+		{[]typeArgument{varchar, varchar}, reflect.TypeOf(NullStringMap{})},
+		{[]typeArgument{varchar, bigint}, reflect.TypeOf(NullMap{})},
+		{[]typeArgument{bigint, varchar}, reflect.TypeOf(NullMap{})},
+	} {
+		converter, err := newTypeConverter("map", typeSignature{RawType: "map", Arguments: tc.arguments})
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, converter.scanType)
+	}
+}
+
+func TestNullRowScan(t *testing.T) {
+	namedField := func(name string, signature typeSignature) typeArgument {
+		return typeArgument{
+			Kind: KIND_NAMED_TYPE,
+			namedTypeSignature: namedTypeSignature{
+				FieldName:     rowFieldName{Name: name},
+				TypeSignature: signature,
+			},
+		}
+	}
+	converter, err := newTypeConverter("row(x varchar, y double, ts timestamp, inner row(id bigint))", typeSignature{
+		RawType: "row",
+		Arguments: []typeArgument{
+			namedField("x", typeSignature{RawType: "varchar"}),
+			namedField("y", typeSignature{RawType: "double"}),
+			namedField("ts", typeSignature{RawType: "timestamp"}),
+			namedField("inner", typeSignature{
+				RawType:   "row",
+				Arguments: []typeArgument{namedField("id", typeSignature{RawType: "bigint"})},
+			}),
+		},
+	})
+	require.NoError(t, err)
+
+	type inner struct {
+		ID int32
+	}
+	type point struct {
+		X         string
+		Y         *float64
+		Timestamp NullTime `trino:"ts"`
+		Inner     inner
+		Ignored   string `trino:"-"`
+	}
+
+	raw := []interface{}{"a", json.Number("1.5"), "2017-07-10 01:02:03.000", []interface{}{json.Number("7")}}
+	value, err := converter.ConvertValue(raw)
+	require.NoError(t, err)
+	assert.Equal(t, RowValue{
+		Names: []string{"x", "y", "ts", "inner"},
+		Values: []interface{}{
+			"a",
+			1.5,
+			time.Date(2017, 7, 10, 1, 2, 3, 0, time.Local),
+			RowValue{Names: []string{"id"}, Values: []interface{}{int64(7)}},
+		},
+	}, value)
+	assert.Equal(t, []interface{}{"a", json.Number("1.5"), "2017-07-10 01:02:03.000", []interface{}{json.Number("7")}}, raw, "raw value modified")
+
+	var row NullRow[point]
+	require.NoError(t, row.Scan(value))
+	assert.True(t, row.Valid)
+	assert.Equal(t, "a", row.Row.X)
+	require.NotNil(t, row.Row.Y)
+	assert.Equal(t, 1.5, *row.Row.Y)
+	assert.Equal(t, NullTime{Valid: true, Time: time.Date(2017, 7, 10, 1, 2, 3, 0, time.Local)}, row.Row.Timestamp)
+	assert.Equal(t, inner{ID: 7}, row.Row.Inner)
+
+	value, err = converter.ConvertValue([]interface{}{nil, nil, nil, nil})
+	require.NoError(t, err)
+	require.NoError(t, row.Scan(value))
+	assert.True(t, row.Valid)
+	assert.Equal(t, point{}, row.Row)
+
+	require.NoError(t, row.Scan(nil))
+	assert.False(t, row.Valid)
+
+	// fields are matched by name, regardless of the order of the struct fields
+	var reordered NullRow[struct {
+		Inner inner
+		Y     float64
+		TS    time.Time
+		X     string
+	}]
+	value, err = converter.ConvertValue(raw)
+	require.NoError(t, err)
+	require.NoError(t, reordered.Scan(value))
+	assert.Equal(t, "a", reordered.Row.X)
+	assert.Equal(t, 1.5, reordered.Row.Y)
+	assert.Equal(t, inner{ID: 7}, reordered.Row.Inner)
+
+	var missing NullRow[struct {
+		X     string
+		Y     float64
+		Inner inner
+	}]
+	assert.ErrorContains(t, missing.Scan(value), `"ts"`, "row field without a struct field scanned with no error")
+
+	// raw rows, like the elements of arrays without the rowValues parameter, are matched by position
+	var anonymous NullRow[struct {
+		A string
+		B int64
+	}]
+	require.NoError(t, anonymous.Scan([]interface{}{"b", json.Number("2")}))
+	assert.Equal(t, "b", anonymous.Row.A)
+	assert.Equal(t, int64(2), anonymous.Row.B)
+
+	var tagged NullRow[struct {
+		A string `trino:"a"`
+	}]
+	assert.Error(t, tagged.Scan([]interface{}{"b"}), "raw row scanned into a struct with field name tags with no error")
+
+	var mismatched NullRow[struct{ X int64 }]
+	value, err = converter.ConvertValue([]interface{}{"a", nil, nil, nil})
+	require.NoError(t, err)
+	assert.Error(t, mismatched.Scan(value), "mismatched types scanned with no error")
+
+	_, err = converter.ConvertValue([]interface{}{"a"})
+	assert.Error(t, err, "row with missing fields converted with no error")
+
+	var notStruct NullRow[string]
+	assert.Error(t, notStruct.Scan([]interface{}{"a"}), "row scanned into a string with no error")
+}
+
+func TestRowValues(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(&stmtResponse{
+				ID:      "fake-query",
+				NextURI: ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		field := func(name, rawType string) string {
+			return `{"kind":"NAMED_TYPE","value":{"fieldName":{"name":"` + name + `"},"typeSignature":{"rawType":"` + rawType + `","arguments":[]}}}`
+		}
+		w.Write([]byte(`{"id":"fake-query","columns":[{"name":"p","type":"row(x bigint, name varchar)","typeSignature":{"rawType":"row","arguments":[` +
+			field("x", "bigint") + `,` + field("name", "varchar") + `]}}],"data":[[[1,"a"]]]}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	type point struct {
+		Label string `trino:"name"`
+		X     int64
+	}
+	// ROW columns carry their field names with or without the rowValues parameter
+	for _, dsn := range []string{ts.URL, ts.URL + "?rowValues=true"} {
+		db, err := sql.Open("trino", dsn)
+		require.NoError(t, err)
+
+		var value interface{}
+		require.NoError(t, db.QueryRow("SELECT p").Scan(&value))
+		assert.Equal(t, RowValue{Names: []string{"x", "name"}, Values: []interface{}{int64(1), "a"}}, value, dsn)
+
+		var row NullRow[point]
+		require.NoError(t, db.QueryRow("SELECT p").Scan(&row))
+		assert.Equal(t, point{Label: "a", X: 1}, row.Row, dsn)
+		assert.NoError(t, db.Close())
+	}
+}
+
+func TestArrayOfRowsConversion(t *testing.T) {
+	rowSignature := func(names ...string) typeSignature {
+		signature := typeSignature{RawType: "row"}
+		for _, name := range names {
+			var fieldName rowFieldName
+			if name != "" {
+				fieldName.Name = name
+			}
+			signature.Arguments = append(signature.Arguments, typeArgument{
+				Kind: KIND_NAMED_TYPE,
+				namedTypeSignature: namedTypeSignature{
+					FieldName:     fieldName,
+					TypeSignature: typeSignature{RawType: "varchar"},
+				},
+			})
+		}
+		return signature
+	}
+	arrayOf := func(element typeSignature) typeSignature {
+		return typeSignature{
+			RawType:   "array",
+			Arguments: []typeArgument{{Kind: KIND_TYPE, typeSignature: element}},
+		}
+	}
+
+	named, err := newTypeConverter("array(row(x varchar, y varchar))", arrayOf(rowSignature("x", "y")))
+	require.NoError(t, err)
+	assert.Equal(t, reflect.TypeOf((*interface{})(nil)).Elem(), named.scanType)
+
+	value, err := named.ConvertValue([]interface{}{[]interface{}{"a", "b"}, nil})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{"a", "b"}, nil}, value)
+
+	type point struct {
+		X string
+		Y string
+	}
+	var positional NullSliceRow[point]
+	require.NoError(t, positional.Scan(value))
+	assert.True(t, positional.Valid)
+	assert.Equal(t, []NullRow[point]{{Row: point{X: "a", Y: "b"}, Valid: true}, {}}, positional.SliceRow)
+
+	var slice NullSliceRowMap
+	assert.Error(t, slice.Scan(value), "positional rows scanned into maps with no error")
+
+	named.setRowValues()
+	value, err = named.ConvertValue([]interface{}{[]interface{}{"a", "b"}, nil})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{RowValue{Names: []string{"x", "y"}, Values: []interface{}{"a", "b"}}, nil}, value)
+
+	require.NoError(t, slice.Scan(value))
+	assert.True(t, slice.Valid)
+	assert.Equal(t, []NullMap{{Map: map[string]interface{}{"x": "a", "y": "b"}, Valid: true}, {Map: map[string]interface{}{}}}, slice.SliceRowMap)
+
+	var rows NullSliceRow[point]
+	require.NoError(t, rows.Scan(value))
+	assert.Equal(t, []NullRow[point]{{Row: point{X: "a", Y: "b"}, Valid: true}, {}}, rows.SliceRow)
+
+	value, err = named.ConvertValue(nil)
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	require.NoError(t, slice.Scan(value))
+	assert.False(t, slice.Valid)
+
+	_, err = named.ConvertValue([]interface{}{[]interface{}{"a"}})
+	assert.Error(t, err, "row with missing fields converted with no error")
+
+	anonymous, err := newTypeConverter("array(row(varchar, varchar))", arrayOf(rowSignature("", "")))
+	require.NoError(t, err)
+	anonymous.setRowValues()
+
+	value, err = anonymous.ConvertValue([]interface{}{[]interface{}{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{"a", "b"}}, value)
+
+	require.NoError(t, rows.Scan(value))
+	assert.Equal(t, []NullRow[point]{{Row: point{X: "a", Y: "b"}, Valid: true}}, rows.SliceRow)
+	assert.Error(t, slice.Scan(value), "anonymous rows scanned into maps with no error")
+}
+
+func TestArrayOfMapsConversion(t *testing.T) {
+	typeOf := func(rawType string, arguments ...typeSignature) typeSignature {
+		signature := typeSignature{RawType: rawType}
+		for _, argument := range arguments {
+			signature.Arguments = append(signature.Arguments, typeArgument{Kind: KIND_TYPE, typeSignature: argument})
+		}
+		return signature
+	}
+	varchar, integer := typeOf("varchar"), typeOf("integer")
+
+	for _, tt := range []struct {
+		name      string
+		signature typeSignature
+		value     interface{}
+		want      []NullMap
+	}{
+		{
+			name:      "array(map(varchar, integer))",
+			signature: typeOf("array", typeOf("map", varchar, integer)),
+			value:     []interface{}{map[string]interface{}{"a": float64(1)}, nil},
+			want:      []NullMap{{Map: map[string]interface{}{"a": float64(1)}, Valid: true}, {Map: map[string]interface{}{}}},
+		},
+		{
+			name:      "array(map(varchar, array(integer)))",
+			signature: typeOf("array", typeOf("map", varchar, typeOf("array", integer))),
+			value:     []interface{}{map[string]interface{}{"a": []interface{}{float64(1), nil}}},
+			want:      []NullMap{{Map: map[string]interface{}{"a": []interface{}{float64(1), nil}}, Valid: true}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			converter, err := newTypeConverter(tt.name, tt.signature)
+			require.NoError(t, err)
+			assert.Equal(t, reflect.TypeOf(NullSliceMap{}), converter.scanType)
+
+			value, err := converter.ConvertValue(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, value)
+
+			var slice NullSliceMap
+			require.NoError(t, slice.Scan(value))
+			assert.True(t, slice.Valid)
+			assert.Equal(t, tt.want, slice.SliceMap)
+
+			value, err = converter.ConvertValue(nil)
+			require.NoError(t, err)
+			assert.Nil(t, value)
+
+			_, err = converter.ConvertValue([]interface{}{"a"})
+			assert.Error(t, err, "non-map element converted with no error")
+		})
+	}
+}
+
 func BenchmarkQuery(b *testing.B) {
 	c := &Config{
 		ServerURI:         *integrationServerFlag,
@@ -1848,6 +5304,71 @@ func BenchmarkQuery(b *testing.B) {
 	}
 }
 
+// BenchmarkDecodeResponse measures the allocations of decoding a response with many inline rows.
+// The response is decoded while it is read, without first buffering the whole body.
+func BenchmarkDecodeResponse(b *testing.B) {
+	data := make([]queryData, 10000)
+	for i := range data {
+		data[i] = queryData{json.Number(strconv.Itoa(i)), strings.Repeat("x", 100), nil}
+	}
+	body, err := json.Marshal(&queryResponse{ID: "fake-query", Data: data})
+	require.NoError(b, err)
+
+	conn := &Conn{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		resp := &http.Response{Body: io.NopCloser(bytes.NewReader(body))}
+		var qresp queryResponse
+		if err := conn.decodeResponse(resp, &qresp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// countingReader counts the calls to Read of the underlying reader.
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(p)
+}
+
+// BenchmarkDecodeResponseReadBufferSize compares decoding a response with many inline rows
+// using different read buffer sizes, reporting the number of reads of the response body.
+func BenchmarkDecodeResponseReadBufferSize(b *testing.B) {
+	data := make([]queryData, 10000)
+	for i := range data {
+		data[i] = queryData{json.Number(strconv.Itoa(i)), strings.Repeat("x", 100), nil}
+	}
+	body, err := json.Marshal(&queryResponse{ID: "fake-query", Data: data})
+	require.NoError(b, err)
+
+	for _, size := range []int{4 << 10, 64 << 10, 512 << 10} {
+		b.Run(strconv.Itoa(size>>10)+"KB", func(b *testing.B) {
+			conn := &Conn{readBufferSize: size}
+			reads := 0
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				r := &countingReader{Reader: bytes.NewReader(body)}
+				resp := &http.Response{Body: io.NopCloser(r)}
+				var qresp queryResponse
+				if err := conn.decodeResponse(resp, &qresp); err != nil {
+					b.Fatal(err)
+				}
+				reads += r.reads
+			}
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+		})
+	}
+}
+
 func TestExec(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping test in short mode.")
@@ -1912,6 +5433,140 @@ func TestExec(t *testing.T) {
 	require.NoError(t, err, "Failed executing DROP TABLE query")
 }
 
+func TestCheckNamedValueValuer(t *testing.T) {
+	st := &driverStmt{}
+	for _, tc := range []struct {
+		value    interface{}
+		expected driver.Value
+	}{
+		{sql.NullInt64{}, nil},
+		{sql.NullInt64{Int64: 1, Valid: true}, int64(1)},
+		{sql.NullInt32{Int32: 1, Valid: true}, int64(1)},
+		{sql.NullInt16{Int16: 1, Valid: true}, int64(1)},
+		{sql.NullByte{Byte: 1, Valid: true}, int64(1)},
+		{sql.NullFloat64{Float64: 1.5, Valid: true}, 1.5},
+		{sql.NullBool{Bool: true, Valid: true}, true},
+		{sql.NullString{String: "x", Valid: true}, "x"},
+		{sql.NullString{}, nil},
+		{sql.NullTime{Time: time.Date(2017, 7, 10, 0, 0, 0, 0, time.UTC), Valid: true}, time.Date(2017, 7, 10, 0, 0, 0, 0, time.UTC)},
+		{(*sql.NullString)(nil), nil},
+		{NullTime{}, nil},
+		{NullTime{Time: time.Date(2017, 7, 10, 0, 0, 0, 0, time.UTC), Valid: true}, time.Date(2017, 7, 10, 0, 0, 0, 0, time.UTC)},
+		{NullSliceBool{SliceBool: []sql.NullBool{{Bool: true, Valid: true}}, Valid: true}, []sql.NullBool{{Bool: true, Valid: true}}},
+		{NullMap{Map: map[string]interface{}{"a": "x"}, Valid: true}, TrinoMap([]string{"a"}, []interface{}{"x"})},
+	} {
+		arg := &driver.NamedValue{Ordinal: 1, Value: tc.value}
+		err := st.CheckNamedValue(arg)
+		if err != nil {
+			assert.ErrorIs(t, err, driver.ErrSkip)
+		}
+		assert.Equal(t, tc.expected, arg.Value, "value %#v", tc.value)
+	}
+}
+
+func TestCheckNamedValuePointers(t *testing.T) {
+	date := Date(2017, 7, 10)
+	tm := Time(11, 34, 25, 0)
+	timeTz := TimeTz(11, 34, 25, 0, time.UTC)
+	timestamp := Timestamp(2017, 7, 10, 11, 34, 25, 0)
+
+	st := &driverStmt{}
+	for _, tc := range []struct {
+		value    interface{}
+		expected driver.Value
+	}{
+		{&date, date},
+		{&tm, tm},
+		{&timeTz, timeTz},
+		{&timestamp, timestamp},
+		{(*trinoDate)(nil), nil},
+		{(*trinoTime)(nil), nil},
+		{(*trinoTimeTz)(nil), nil},
+		{(*trinoTimestamp)(nil), nil},
+	} {
+		arg := &driver.NamedValue{Ordinal: 1, Value: tc.value}
+		require.NoError(t, st.CheckNamedValue(arg))
+		assert.Equal(t, tc.expected, arg.Value, "value %#v", tc.value)
+	}
+
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		queries = append(queries, string(body))
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+
+	_, err = db.Exec("INSERT INTO t (a, b) VALUES (?, ?)", &date, (*trinoDate)(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EXECUTE IMMEDIATE 'INSERT INTO t (a, b) VALUES (?, ?)' USING DATE '2017-07-10', NULL"}, queries)
+}
+
+func TestCheckNamedValueDriverValueSlice(t *testing.T) {
+	st := &driverStmt{}
+	arg := &driver.NamedValue{Ordinal: 1, Value: []driver.Value{int64(1), int64(2)}}
+	require.NoError(t, st.CheckNamedValue(arg))
+	assert.Equal(t, []driver.Value{int64(1), int64(2)}, arg.Value)
+
+	s, err := Serial(arg.Value)
+	require.NoError(t, err)
+	assert.Equal(t, "ARRAY[1, 2]", s)
+}
+
+func TestExplicitPrepare(t *testing.T) {
+	c := &Config{
+		ServerURI:       "http://foobar@localhost:8080",
+		ExplicitPrepare: true,
+	}
+
+	dsn, err := c.FormatDSN()
+	require.NoError(t, err)
+
+	want := "http://foobar@localhost:8080?explicitPrepare=true&source=trino-go-client"
+	assert.Equal(t, want, dsn)
+
+	var query string
+	var preparedStatements []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		query = string(b)
+		preparedStatements = r.Header.Values(preparedStatementHeader)
+		json.NewEncoder(w).Encode(&stmtResponse{ID: "fake-query"})
+	}))
+
+	t.Cleanup(ts.Close)
+
+	for _, tc := range []struct {
+		dsn                        string
+		expectedQuery              string
+		expectedPreparedStatements []string
+	}{
+		{
+			dsn:           ts.URL,
+			expectedQuery: "EXECUTE IMMEDIATE 'SELECT ?' USING 1",
+		},
+		{
+			dsn:                        ts.URL + "?explicitPrepare=true",
+			expectedQuery:              "EXECUTE _trino_go USING 1",
+			expectedPreparedStatements: []string{"_trino_go=SELECT+%3F"},
+		},
+	} {
+		db, err := sql.Open("trino", tc.dsn)
+		require.NoError(t, err)
+
+		_, err = db.Exec("SELECT ?", 1)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedQuery, query)
+		assert.Equal(t, tc.expectedPreparedStatements, preparedStatements)
+
+		assert.NoError(t, db.Close())
+	}
+}
+
 func TestForwardAuthorizationHeaderConfig(t *testing.T) {
 	c := &Config{
 		ServerURI:                  "https://foobar@localhost:8090",
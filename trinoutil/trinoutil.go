@@ -0,0 +1,162 @@
+// Copyright (c) Facebook, Inc. and its affiliates. All Rights Reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trinoutil provides helpers to read query results returned by the
+// trino driver, or any other database/sql driver, into Go values.
+package trinoutil
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ScanFunc scans the current row of rows into dest.
+type ScanFunc[T any] func(rows *sql.Rows, dest *T) error
+
+// ScanAll calls scanFn for every row of rows and returns the scanned values.
+// Rows are always closed, and any error reported by rows.Err is returned.
+//
+// If scanFn is nil, each row is scanned with ScanStruct when T is a struct,
+// or as a single column into a T otherwise.
+func ScanAll[T any](rows *sql.Rows, scanFn ScanFunc[T]) ([]T, error) {
+	defer rows.Close()
+	if scanFn == nil {
+		scanFn = defaultScanFunc[T]()
+	}
+	var results []T
+	for rows.Next() {
+		var r T
+		if err := scanFn(rows, &r); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ScanOne calls scanFn for the first row of rows and returns the scanned value.
+// If there are no rows, it returns sql.ErrNoRows. Remaining rows are discarded,
+// and rows are always closed.
+//
+// If scanFn is nil, the row is scanned as described in ScanAll.
+func ScanOne[T any](rows *sql.Rows, scanFn ScanFunc[T]) (T, error) {
+	defer rows.Close()
+	if scanFn == nil {
+		scanFn = defaultScanFunc[T]()
+	}
+	var r T
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return r, err
+		}
+		return r, sql.ErrNoRows
+	}
+	if err := scanFn(rows, &r); err != nil {
+		return r, err
+	}
+	if err := rows.Close(); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+func defaultScanFunc[T any]() ScanFunc[T] {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType) {
+		return func(rows *sql.Rows, dest *T) error {
+			return ScanStruct(rows, dest)
+		}
+	}
+	return func(rows *sql.Rows, dest *T) error {
+		return rows.Scan(dest)
+	}
+}
+
+// ScanStruct scans the current row of rows into the fields of the struct
+// pointed to by dest. Each column is stored in the exported field with a
+// `trino` tag equal to the column name, or else in the exported field whose
+// name matches the column name, ignoring case and underscores. Fields tagged
+// with `trino:"-"` are skipped. It returns an error if a column has no
+// matching field, or if two fields have the same tag or matching names.
+func ScanStruct(rows *sql.Rows, dest any) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("trinoutil: destination must be a non-nil pointer to a struct, got %T", dest)
+	}
+	v = v.Elem()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	tags, names, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	targets := make([]any, len(columns))
+	for i, column := range columns {
+		index, ok := tags[column]
+		if !ok {
+			index, ok = names[normalizeName(column)]
+		}
+		if !ok {
+			return fmt.Errorf("trinoutil: no field of %s matches column %q", v.Type(), column)
+		}
+		targets[i] = v.Field(index).Addr().Interface()
+	}
+	return rows.Scan(targets...)
+}
+
+// structFields maps tag values and normalized names of untagged fields to field indexes.
+// It returns an error if two fields have the same tag or normalized name.
+func structFields(t reflect.Type) (tags, names map[string]int, err error) {
+	tags = make(map[string]int)
+	names = make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("trino")
+		if tag == "-" {
+			continue
+		}
+		fields, key := names, normalizeName(f.Name)
+		if tag != "" {
+			fields, key = tags, tag
+		}
+		if j, ok := fields[key]; ok {
+			return nil, nil, fmt.Errorf("trinoutil: fields %s and %s of %s match the same column", t.Field(j).Name, f.Name, t)
+		}
+		fields[key] = i
+	}
+	return tags, names, nil
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
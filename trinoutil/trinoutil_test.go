@@ -0,0 +1,150 @@
+// Copyright (c) Facebook, Inc. and its affiliates. All Rights Reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trinoutil
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/trinodb/trino-go-client/trino"
+)
+
+// newTestDB returns a database whose queries all return the id and name columns with the given rows.
+func newTestDB(t *testing.T, data [][]any) *sql.DB {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			json.NewEncoder(w).Encode(map[string]any{
+				"id":      "fake-query",
+				"nextUri": ts.URL + "/v1/statement/20210817_140827_00000_arvdv/1",
+			})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "fake-query",
+			"columns": []map[string]any{
+				{"name": "id", "type": "bigint", "typeSignature": map[string]any{"rawType": "bigint"}},
+				{"name": "user_name", "type": "varchar", "typeSignature": map[string]any{"rawType": "varchar"}},
+			},
+			"data":  data,
+			"stats": map[string]any{"state": "FINISHED"},
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	db, err := sql.Open("trino", ts.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, db.Close()) })
+	return db
+}
+
+type user struct {
+	ID   int64
+	Name string `trino:"user_name"`
+}
+
+func TestScanAll(t *testing.T) {
+	db := newTestDB(t, [][]any{{1, "alice"}, {2, "bob"}})
+
+	rows, err := db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	users, err := ScanAll(rows, func(rows *sql.Rows, u *user) error {
+		return rows.Scan(&u.ID, &u.Name)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []user{{1, "alice"}, {2, "bob"}}, users)
+
+	rows, err = db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	users, err = ScanAll[user](rows, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []user{{1, "alice"}, {2, "bob"}}, users)
+}
+
+func TestScanAllEmpty(t *testing.T) {
+	db := newTestDB(t, nil)
+
+	rows, err := db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	users, err := ScanAll[user](rows, nil)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestScanAllError(t *testing.T) {
+	db := newTestDB(t, [][]any{{1, "alice"}})
+
+	rows, err := db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	_, err = ScanAll(rows, func(rows *sql.Rows, id *int64) error {
+		return rows.Scan(id)
+	})
+	assert.ErrorContains(t, err, "expected 2 destination arguments")
+
+	rows, err = db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	_, err = ScanAll[struct{ ID int64 }](rows, nil)
+	assert.EqualError(t, err, `trinoutil: no field of struct { ID int64 } matches column "user_name"`)
+
+	rows, err = db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	_, err = ScanAll[struct {
+		ID        int64
+		UserName  string
+		User_Name string
+	}](rows, nil)
+	assert.ErrorContains(t, err, "fields UserName and User_Name")
+}
+
+func TestScanStructTagsAndNames(t *testing.T) {
+	db := newTestDB(t, [][]any{{1, "alice"}})
+
+	type account struct {
+		ID       int64
+		Login    string `trino:"username"`
+		UserName string
+	}
+	rows, err := db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	a, err := ScanOne[account](rows, nil)
+	require.NoError(t, err)
+	assert.Equal(t, account{ID: 1, UserName: "alice"}, a, "tags should only match columns with the same name")
+}
+
+func TestScanOne(t *testing.T) {
+	db := newTestDB(t, [][]any{{1, "alice"}, {2, "bob"}})
+
+	rows, err := db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	u, err := ScanOne[user](rows, nil)
+	require.NoError(t, err)
+	assert.Equal(t, user{1, "alice"}, u)
+
+	db = newTestDB(t, nil)
+	rows, err = db.Query("SELECT id, user_name FROM users")
+	require.NoError(t, err)
+	_, err = ScanOne[user](rows, nil)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}